@@ -0,0 +1,445 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/mattn/go-isatty"
+)
+
+// command is a subcommand of mdrs, like "config check"
+type command struct {
+	name    string
+	args    string
+	summary string
+	// words are the possible arguments, when there is a fixed set
+	words []string
+	// flags registers the flags of the command, if any
+	flags func(f *commandFlags)
+	run   func(f *commandFlags, args []string) error
+}
+
+// errUsage is returned by the commands called with wrong arguments, after
+// printing the problem
+var errUsage = errors.New("usage error")
+
+// commandList lists the commands, in the order of the help. The first one
+// runs when no command is given.
+func commandList() []*command {
+	return []*command{
+		{
+			name:    "view",
+			args:    "[FILE]",
+			summary: "Read a markdown file, or stdin, in the terminal (the default command)",
+			flags: func(f *commandFlags) {
+				f.addConfigOptions()
+				f.addEncoding()
+				f.BoolVar(&f.noRestore, "no-restore", false, "")
+				f.help("--no-restore", "Start at the top instead of the last reading position")
+			},
+			run: func(f *commandFlags, args []string) error {
+				if err := f.checkDocumentArgs(args); err != nil {
+					return err
+				}
+				return viewFile(args, f.encoding, !f.noRestore)
+			},
+		},
+		{
+			name:    "export",
+			args:    "[FILE]",
+			summary: "Print a rendered markdown file, or stdin",
+			flags: func(f *commandFlags) {
+				f.addConfigOptions()
+				f.addEncoding()
+				f.BoolVar(&f.plain, "plain", false, "")
+				f.help("--plain", "Print the text without colors and styles")
+			},
+			run: func(f *commandFlags, args []string) error {
+				if err := f.checkDocumentArgs(args); err != nil {
+					return err
+				}
+				return exportFile(os.Stdout, args, f.encoding, f.plain)
+			},
+		},
+		{
+			name:    "config init",
+			summary: "Create the user config file",
+			flags: func(f *commandFlags) {
+				f.StringVar(&f.format, "format", "", "")
+				f.help("--format FORMAT", "Format of the config file: json, yaml or toml")
+			},
+			run: func(f *commandFlags, args []string) error {
+				if len(args) > 0 {
+					return f.usageError("unexpected argument %q", args[0])
+				}
+				initConfig(f.format)
+				return nil
+			},
+		},
+		{
+			name:    "config path",
+			summary: "Show the locations of the config files",
+			run: func(f *commandFlags, args []string) error {
+				if len(args) > 0 {
+					return f.usageError("unexpected argument %q", args[0])
+				}
+				printConfigPaths(os.Stdout)
+				return nil
+			},
+		},
+		{
+			name:    "config check",
+			args:    "[FILE]",
+			summary: "Validate the config files used for a file, or the current directory",
+			run: func(f *commandFlags, args []string) error {
+				if len(args) > 1 {
+					return f.usageError("only one file is supported")
+				}
+				checkConfig(os.Stdout, configDir(args))
+				return nil
+			},
+		},
+		{
+			name:    "config show",
+			args:    "[FILE]",
+			summary: "Show the config used for a file, or the current directory, and where each value comes from",
+			flags: func(f *commandFlags) {
+				f.addConfigOptions()
+			},
+			run: func(f *commandFlags, args []string) error {
+				if len(args) > 1 {
+					return f.usageError("only one file is supported")
+				}
+				showConfig(os.Stdout, configDir(args))
+				return nil
+			},
+		},
+		{
+			name:    "completion",
+			args:    "bash|zsh|fish",
+			summary: "Print the completion script of a shell",
+			words:   completionShells,
+			run: func(f *commandFlags, args []string) error {
+				if len(args) != 1 {
+					return f.usageError("expected a shell: %s", strings.Join(completionShells, ", "))
+				}
+				return printCompletion(os.Stdout, args[0])
+			},
+		},
+		{
+			name:    "man",
+			summary: "Print the man page",
+			run: func(f *commandFlags, args []string) error {
+				if len(args) > 0 {
+					return f.usageError("unexpected argument %q", args[0])
+				}
+				printManPage(os.Stdout)
+				return nil
+			},
+		},
+		{
+			name:    "version",
+			summary: "Show the version",
+			run: func(f *commandFlags, args []string) error {
+				printVersion()
+				return nil
+			},
+		},
+	}
+}
+
+// legacyCommands are the options used as commands before the subcommands,
+// still accepted
+var legacyCommands = map[string][]string{
+	"--version":      {"version"},
+	"--init-config":  {"config", "init"},
+	"--config-path":  {"config", "path"},
+	"--check-config": {"config", "check"},
+	"--show-config":  {"config", "show"},
+}
+
+// runCommand runs the command given by the arguments, viewing a file when
+// there is none
+func runCommand(args []string) error {
+	// The global flags may come before the command, like in
+	// "mdrs --config my.json config show"
+	var global []string
+	for len(args) > 0 {
+		if args[0] == "--config" && len(args) > 1 {
+			global, args = append(global, args[:2]...), args[2:]
+		} else if strings.HasPrefix(args[0], "--config=") {
+			global, args = append(global, args[0]), args[1:]
+		} else {
+			break
+		}
+	}
+
+	if len(args) > 0 {
+		if replacement, ok := legacyCommands[args[0]]; ok {
+			args = append(append([]string{}, replacement...), args[1:]...)
+		}
+	}
+
+	if len(args) > 0 {
+		switch args[0] {
+		case "help", "-h", "-help", "--help":
+			return printHelp(os.Stdout, args[1:])
+		}
+	}
+
+	commands := commandList()
+	cmd, args := findCommand(commands, args)
+	if cmd == nil {
+		if len(args) > 0 && args[0] == "config" {
+			_, _ = fmt.Fprintln(os.Stderr, "mdrs config: expected one of init, path, check or show")
+			_, _ = fmt.Fprintln(os.Stderr, "Run 'mdrs help' for usage.")
+			return errUsage
+		}
+		cmd = commands[0]
+	}
+
+	f := newCommandFlags(cmd)
+	files, err := f.parse(append(global, args...))
+	if err == flag.ErrHelp {
+		f.printUsage(os.Stdout)
+		return nil
+	}
+	if err != nil {
+		return f.usageError("%v", err)
+	}
+	return cmd.run(f, files)
+}
+
+// findCommand finds the command named by the first arguments, returning the
+// arguments left
+func findCommand(commands []*command, args []string) (*command, []string) {
+	for _, cmd := range commands {
+		words := strings.Fields(cmd.name)
+		if len(args) < len(words) {
+			continue
+		}
+		match := true
+		for i, word := range words {
+			if args[i] != word {
+				match = false
+				break
+			}
+		}
+		if match {
+			return cmd, args[len(words):]
+		}
+	}
+	return nil, args
+}
+
+// printHelp prints the usage of mdrs, or of a command
+func printHelp(w io.Writer, args []string) error {
+	if len(args) > 0 {
+		cmd, rest := findCommand(commandList(), args)
+		if cmd == nil || len(rest) > 0 {
+			return fmt.Errorf("unknown command %q", strings.Join(args, " "))
+		}
+		newCommandFlags(cmd).printUsage(w)
+		return nil
+	}
+
+	_, _ = fmt.Fprintln(w, "usage: mdrs [view] [options] [FILE]")
+	_, _ = fmt.Fprintln(w, "       mdrs <command> [options] [arguments]")
+	_, _ = fmt.Fprintln(w)
+	_, _ = fmt.Fprintln(w, "Read markdown in the terminal. Without a file, the markdown is read from stdin.")
+	_, _ = fmt.Fprintln(w)
+	_, _ = fmt.Fprintln(w, "Commands:")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, cmd := range commandList() {
+		_, _ = fmt.Fprintf(tw, "  %s\t%s\n", cmd.name, cmd.summary)
+	}
+	_ = tw.Flush()
+	_, _ = fmt.Fprintln(w)
+	_, _ = fmt.Fprintln(w, "Run 'mdrs help <command>' or 'mdrs <command> --help' for the options of a command.")
+	return nil
+}
+
+// commandFlags are the flags of a command, along with their help
+type commandFlags struct {
+	*flag.FlagSet
+	cmd *command
+
+	// options of the help, by pairs of flags and description
+	options [][2]string
+
+	// values of the flags specific to a command
+	noRestore bool
+	plain     bool
+	format    string
+	encoding  string
+}
+
+func newCommandFlags(cmd *command) *commandFlags {
+	f := &commandFlags{
+		FlagSet: flag.NewFlagSet("mdrs "+cmd.name, flag.ContinueOnError),
+		cmd:     cmd,
+	}
+
+	// The errors and the usage are printed by runCommand
+	f.SetOutput(ioutil.Discard)
+	f.Usage = func() {}
+
+	if cmd.flags != nil {
+		cmd.flags(f)
+	}
+
+	// Every command accepts another config file
+	f.StringVar(&configFlag, "config", configFlag, "")
+	f.help("--config PATH", "Use another config file, like $MDRS_CONFIG")
+
+	return f
+}
+
+// help adds an option to the help of the command
+func (f *commandFlags) help(flags string, description string) {
+	f.options = append(f.options, [2]string{flags, description})
+}
+
+// addConfigOptions adds the flags setting config options, which take
+// precedence over the config files and the environment
+func (f *commandFlags) addConfigOptions() {
+	colors := false
+	for _, option := range configOptions() {
+		name := strings.TrimPrefix(option.flagName(), "--")
+		f.Var(&optionFlag{option: option}, name, "")
+
+		// The colors share a single line of help
+		if strings.HasPrefix(option.path, "colors.") {
+			if !colors {
+				f.help("--color-NAME COLOR", "Set a color, like --color-heading1 '#ff5555', see 'mdrs config show' for the names")
+				colors = true
+			}
+			continue
+		}
+
+		switch {
+		case option.kind == reflect.Bool:
+			f.Var(&optionFlag{option: option, negated: true}, "no-"+name, "")
+			f.help(fmt.Sprintf("--%s, --no-%s", name, name), option.doc)
+		case option.name == "width":
+			f.Var(&optionFlag{option: option}, "w", "")
+			f.help("-w, --width N", option.doc)
+		default:
+			words := strings.Split(option.name, "_")
+			f.help(fmt.Sprintf("--%s %s", name, strings.ToUpper(words[len(words)-1])), option.doc)
+		}
+	}
+}
+
+// addEncoding adds the flag forcing the encoding of the document
+func (f *commandFlags) addEncoding() {
+	f.StringVar(&f.encoding, "encoding", autoEncoding, "")
+	f.help("--encoding NAME", "Encoding of the document: "+strings.Join(encodingNames(), ", ")+", detected by default")
+}
+
+// parse parses the flags of the command, which may come before or after the
+// other arguments. The arguments after "--" are never flags.
+func (f *commandFlags) parse(args []string) ([]string, error) {
+	var result []string
+	for {
+		if err := f.Parse(args); err != nil {
+			return nil, err
+		}
+
+		rest := f.Args()
+		if len(rest) == 0 {
+			return result, nil
+		}
+		if len(rest) < len(args) && args[len(args)-len(rest)-1] == "--" {
+			return append(result, rest...), nil
+		}
+		result = append(result, rest[0])
+		args = rest[1:]
+	}
+}
+
+// checkDocumentArgs checks that the arguments give a single markdown file,
+// or none to read stdin
+func (f *commandFlags) checkDocumentArgs(args []string) error {
+	if len(args) > 1 {
+		return f.usageError("only one file is supported")
+	}
+	if len(args) == 0 && isatty.IsTerminal(os.Stdin.Fd()) {
+		return f.usageError("no file given, and nothing to read on stdin")
+	}
+	if f.encoding != autoEncoding {
+		if _, err := encodingByName(f.encoding); err != nil {
+			return f.usageError("%v", err)
+		}
+	}
+	return nil
+}
+
+// usageError prints a problem with the arguments of the command
+func (f *commandFlags) usageError(format string, args ...interface{}) error {
+	_, _ = fmt.Fprintf(os.Stderr, "mdrs %s: %s\n", f.cmd.name, fmt.Sprintf(format, args...))
+	_, _ = fmt.Fprintf(os.Stderr, "Run 'mdrs %s --help' for usage.\n", f.cmd.name)
+	return errUsage
+}
+
+// printUsage prints the help of the command
+func (f *commandFlags) printUsage(w io.Writer) {
+	usage := "mdrs " + f.cmd.name + " [options]"
+	if f.cmd.args != "" {
+		usage += " " + f.cmd.args
+	}
+	_, _ = fmt.Fprintf(w, "usage: %s\n\n%s.\n\nOptions:\n", usage, f.cmd.summary)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, option := range f.options {
+		_, _ = fmt.Fprintf(tw, "  %s\t%s\n", option[0], option[1])
+	}
+	_, _ = fmt.Fprintf(tw, "  %s\t%s\n", "-h, --help", "Show this help")
+	_ = tw.Flush()
+}
+
+// optionFlag is a command line flag setting a config option
+type optionFlag struct {
+	option configOption
+	// negated clears a boolean option, like --no-center
+	negated bool
+}
+
+func (f *optionFlag) String() string {
+	return ""
+}
+
+func (f *optionFlag) IsBoolFlag() bool {
+	return f.option.kind == reflect.Bool
+}
+
+func (f *optionFlag) Set(text string) error {
+	if f.negated {
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return err
+		}
+		text = strconv.FormatBool(!b)
+	}
+
+	// Check the value now, to report it like the other flags
+	if err := DefaultConfig().setOption(f.option, text, nil); err != nil {
+		return err
+	}
+	if f.option.name == "theme" {
+		if _, err := themeByName(text); err != nil {
+			return err
+		}
+	}
+
+	commandLineOptions = append(commandLineOptions, configOptValue{option: f.option, text: text})
+	return nil
+}
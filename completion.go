@@ -0,0 +1,532 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// completionFlag is a flag of a command, as offered by the completion
+type completionFlag struct {
+	// name of the flag without dashes, like "theme" or "w"
+	name string
+	doc  string
+	// takesValue tells if the flag is followed by a value
+	takesValue bool
+	// values are the possible values of the flag, if known
+	values []string
+	// files tells if the value is a file
+	files bool
+}
+
+// dashed returns the flag as typed, -w for the single letters and --theme
+// for the others
+func (f completionFlag) dashed() string {
+	if len(f.name) == 1 {
+		return "-" + f.name
+	}
+	return "--" + f.name
+}
+
+// completionCommand is a command, as offered by the completion
+type completionCommand struct {
+	name  string
+	doc   string
+	flags []completionFlag
+	// markdown tells if the command takes a markdown file
+	markdown bool
+	// words are the fixed arguments of the command, like the shells of
+	// the completion command
+	words []string
+}
+
+// completionCommands lists the commands and their flags, from the command
+// line definition
+func completionCommands() []completionCommand {
+	var result []completionCommand
+	for _, cmd := range commandList() {
+		f := newCommandFlags(cmd)
+
+		// The description of the flags, from the help
+		docs := make(map[string]string)
+		for _, option := range f.options {
+			for _, name := range strings.Split(option[0], ", ") {
+				name = strings.Fields(name)[0]
+				docs[strings.TrimLeft(name, "-")] = option[1]
+			}
+		}
+
+		var flags []completionFlag
+		f.VisitAll(func(fl *flag.Flag) {
+			cf := completionFlag{name: fl.Name, doc: docs[fl.Name]}
+			if b, ok := fl.Value.(interface{ IsBoolFlag() bool }); !ok || !b.IsBoolFlag() {
+				cf.takesValue = true
+			}
+			if of, ok := fl.Value.(*optionFlag); ok {
+				switch {
+				case of.negated:
+					cf.doc = "Turn off --" + strings.Replace(of.option.name, "_", "-", -1)
+				default:
+					cf.doc = of.option.doc
+				}
+				if of.option.name == "theme" {
+					for _, t := range themes {
+						cf.values = append(cf.values, t.name)
+					}
+				}
+			}
+			switch fl.Name {
+			case "config":
+				cf.files = true
+			case "format":
+				for _, format := range configFormats {
+					cf.values = append(cf.values, format.name)
+				}
+			case "encoding":
+				cf.values = append([]string{autoEncoding}, encodingNames()...)
+			}
+			flags = append(flags, cf)
+		})
+		flags = append(flags, completionFlag{name: "help", doc: "Show the help"})
+
+		result = append(result, completionCommand{
+			name:     cmd.name,
+			doc:      cmd.summary,
+			flags:    flags,
+			markdown: cmd.args == "[FILE]",
+			words:    cmd.words,
+		})
+	}
+	return result
+}
+
+// completionShells are the shells with a completion script
+var completionShells = []string{"bash", "zsh", "fish"}
+
+// printCompletion prints the completion script of a shell
+func printCompletion(w io.Writer, shell string) error {
+	commands := completionCommands()
+	switch shell {
+	case "bash":
+		_, _ = io.WriteString(w, bashCompletion(commands))
+	case "zsh":
+		_, _ = io.WriteString(w, zshCompletion(commands))
+	case "fish":
+		_, _ = io.WriteString(w, fishCompletion(commands))
+	default:
+		return fmt.Errorf("unknown shell %q, expected %s", shell, strings.Join(completionShells, ", "))
+	}
+	return nil
+}
+
+// topCommands returns the first word of each command, once
+func topCommands(commands []completionCommand) []string {
+	var words []string
+	for _, cmd := range commands {
+		word := strings.Fields(cmd.name)[0]
+		if !containsString(words, word) {
+			words = append(words, word)
+		}
+	}
+	return words
+}
+
+// subCommands returns the second words of the commands starting with word
+func subCommands(commands []completionCommand, word string) []completionCommand {
+	var result []completionCommand
+	for _, cmd := range commands {
+		words := strings.Fields(cmd.name)
+		if len(words) == 2 && words[0] == word {
+			result = append(result, cmd)
+		}
+	}
+	return result
+}
+
+// valueFlags lists the flags taking a value, as typed
+func valueFlags(commands []completionCommand) []string {
+	var names []string
+	for _, cmd := range commands {
+		for _, f := range cmd.flags {
+			if f.takesValue && !containsString(names, f.dashed()) {
+				names = append(names, f.dashed())
+			}
+		}
+	}
+	return names
+}
+
+func bashCompletion(commands []completionCommand) string {
+	var sb strings.Builder
+	top := topCommands(commands)
+
+	sb.WriteString("# bash completion for mdrs, generated by \"mdrs completion bash\"\n")
+	sb.WriteString("# Load it with: source <(mdrs completion bash)\n\n")
+
+	// The command is the first word that isn't a flag or its value, a file
+	// being viewed
+	sb.WriteString("__mdrs_command() {\n")
+	sb.WriteString("    local i word skip=0\n")
+	sb.WriteString("    for ((i = 1; i < COMP_CWORD; i++)); do\n")
+	sb.WriteString("        word=\"${COMP_WORDS[i]}\"\n")
+	sb.WriteString("        if ((skip)); then skip=0; continue; fi\n")
+	sb.WriteString("        case \"$word\" in\n")
+	sb.WriteString(fmt.Sprintf("            %s) skip=1 ;;\n", strings.Join(valueFlags(commands), "|")))
+	sb.WriteString("            -*) ;;\n")
+	for _, word := range top {
+		if len(subCommands(commands, word)) > 0 {
+			sb.WriteString(fmt.Sprintf("            %s)\n", word))
+			sb.WriteString(fmt.Sprintf("                if ((i + 1 < COMP_CWORD)); then echo \"%s ${COMP_WORDS[i+1]}\"; else echo %s; fi\n", word, word))
+			sb.WriteString("                return ;;\n")
+		}
+	}
+	var simple []string
+	for _, word := range top {
+		if len(subCommands(commands, word)) == 0 {
+			simple = append(simple, word)
+		}
+	}
+	sb.WriteString(fmt.Sprintf("            %s|help) echo \"$word\"; return ;;\n", strings.Join(simple, "|")))
+	sb.WriteString(fmt.Sprintf("            *) echo %s; return ;;\n", commands[0].name))
+	sb.WriteString("        esac\n")
+	sb.WriteString("    done\n")
+	sb.WriteString("}\n\n")
+
+	sb.WriteString("__mdrs_markdown_files() {\n")
+	sb.WriteString("    compopt -o filenames 2>/dev/null\n")
+	sb.WriteString("    COMPREPLY+=($(compgen -f -X '!*.md' -- \"$cur\") $(compgen -f -X '!*.markdown' -- \"$cur\") $(compgen -d -- \"$cur\"))\n")
+	sb.WriteString("}\n\n")
+
+	sb.WriteString("_mdrs() {\n")
+	sb.WriteString("    local cur=\"${COMP_WORDS[COMP_CWORD]}\" prev=\"${COMP_WORDS[COMP_CWORD-1]}\"\n")
+	sb.WriteString("    local command flags\n")
+	sb.WriteString("    COMPREPLY=()\n")
+	sb.WriteString("    command=\"$(__mdrs_command)\"\n\n")
+
+	// The values of the flags
+	sb.WriteString("    case \"$prev\" in\n")
+	var others []string
+	done := make(map[string]bool)
+	for _, cmd := range commands {
+		for _, f := range cmd.flags {
+			if !f.takesValue || done[f.dashed()] {
+				continue
+			}
+			done[f.dashed()] = true
+			switch {
+			case f.files:
+				sb.WriteString(fmt.Sprintf("        %s) compopt -o filenames 2>/dev/null; COMPREPLY=($(compgen -f -- \"$cur\")); return ;;\n", f.dashed()))
+			case len(f.values) > 0:
+				sb.WriteString(fmt.Sprintf("        %s) COMPREPLY=($(compgen -W \"%s\" -- \"$cur\")); return ;;\n", f.dashed(), strings.Join(f.values, " ")))
+			default:
+				others = append(others, f.dashed())
+			}
+		}
+	}
+	if len(others) > 0 {
+		sb.WriteString(fmt.Sprintf("        %s) return ;;\n", strings.Join(others, "|")))
+	}
+	sb.WriteString("    esac\n\n")
+
+	// The flags and arguments of each command
+	sb.WriteString("    case \"$command\" in\n")
+	sb.WriteString("        \"\")\n")
+	sb.WriteString(fmt.Sprintf("            flags=\"%s\"\n", strings.Join(bashFlags(commands[0]), " ")))
+	sb.WriteString("            if [[ $cur != -* ]]; then\n")
+	sb.WriteString(fmt.Sprintf("                COMPREPLY=($(compgen -W \"%s help\" -- \"$cur\"))\n", strings.Join(top, " ")))
+	sb.WriteString("                __mdrs_markdown_files\n")
+	sb.WriteString("            fi\n")
+	sb.WriteString("            ;;\n")
+	for _, word := range top {
+		if subs := subCommands(commands, word); len(subs) > 0 {
+			var names []string
+			for _, sub := range subs {
+				names = append(names, strings.Fields(sub.name)[1])
+			}
+			sb.WriteString(fmt.Sprintf("        %s) COMPREPLY=($(compgen -W \"%s\" -- \"$cur\")); return ;;\n", word, strings.Join(names, " ")))
+		}
+	}
+	for _, cmd := range commands {
+		sb.WriteString(fmt.Sprintf("        %q)\n", cmd.name))
+		sb.WriteString(fmt.Sprintf("            flags=\"%s\"\n", strings.Join(bashFlags(cmd), " ")))
+		switch {
+		case cmd.markdown:
+			sb.WriteString("            if [[ $cur != -* ]]; then __mdrs_markdown_files; fi\n")
+		case len(cmd.words) > 0:
+			sb.WriteString(fmt.Sprintf("            if [[ $cur != -* ]]; then COMPREPLY=($(compgen -W \"%s\" -- \"$cur\")); fi\n", strings.Join(cmd.words, " ")))
+		}
+		sb.WriteString("            ;;\n")
+	}
+	sb.WriteString(fmt.Sprintf("        help) COMPREPLY=($(compgen -W \"%s\" -- \"$cur\")); return ;;\n", strings.Join(top, " ")))
+	sb.WriteString("    esac\n\n")
+
+	sb.WriteString("    if [[ $cur == -* ]]; then\n")
+	sb.WriteString("        COMPREPLY=($(compgen -W \"$flags\" -- \"$cur\"))\n")
+	sb.WriteString("    fi\n")
+	sb.WriteString("}\n\n")
+	sb.WriteString("complete -F _mdrs mdrs\n")
+
+	return sb.String()
+}
+
+// bashFlags lists the flags of a command, as typed
+func bashFlags(cmd completionCommand) []string {
+	names := make([]string, len(cmd.flags))
+	for i, f := range cmd.flags {
+		names[i] = f.dashed()
+	}
+	return names
+}
+
+func zshCompletion(commands []completionCommand) string {
+	var sb strings.Builder
+	top := topCommands(commands)
+
+	sb.WriteString("#compdef mdrs\n")
+	sb.WriteString("# zsh completion for mdrs, generated by \"mdrs completion zsh\"\n")
+	sb.WriteString("# Save it as _mdrs in a directory of $fpath, or load it with:\n")
+	sb.WriteString("#   source <(mdrs completion zsh); compdef _mdrs mdrs\n\n")
+
+	sb.WriteString("__mdrs_markdown_files() {\n")
+	sb.WriteString("  _files -g '*.(md|markdown)(-.)'\n")
+	sb.WriteString("}\n\n")
+
+	// The arguments of each command, the first word being the command
+	for _, cmd := range commands {
+		sb.WriteString(fmt.Sprintf("__mdrs_%s() {\n", strings.Replace(cmd.name, " ", "_", -1)))
+		sb.WriteString("  _arguments -s \\\n")
+		for _, f := range cmd.flags {
+			sb.WriteString(fmt.Sprintf("    %s \\\n", zshFlagSpec(f)))
+		}
+		switch {
+		case cmd.markdown:
+			sb.WriteString("    '1:markdown file:__mdrs_markdown_files'\n")
+		case len(cmd.words) > 0:
+			sb.WriteString(fmt.Sprintf("    '1:%s:(%s)'\n", strings.Fields(cmd.name)[0], strings.Join(cmd.words, " ")))
+		default:
+			sb.WriteString("    && return 0\n")
+		}
+		sb.WriteString("}\n\n")
+	}
+
+	sb.WriteString("_mdrs() {\n")
+	sb.WriteString("  local curcontext=\"$curcontext\" state line\n")
+	sb.WriteString("  local -a commands\n")
+	sb.WriteString("  commands=(\n")
+	for _, word := range top {
+		doc := ""
+		for _, cmd := range commands {
+			if cmd.name == word {
+				doc = cmd.doc
+			}
+		}
+		if doc == "" {
+			doc = "Manage the " + word
+		}
+		sb.WriteString(fmt.Sprintf("    %s\n", zshQuote(word+":"+strings.Replace(doc, ":", "\\:", -1))))
+	}
+	sb.WriteString("    'help:Show the help of a command'\n")
+	sb.WriteString("  )\n\n")
+
+	// The options of the default command come before the file too
+	sb.WriteString("  _arguments -C -s \\\n")
+	for _, f := range commands[0].flags {
+		sb.WriteString(fmt.Sprintf("    %s \\\n", zshFlagSpec(f)))
+	}
+	sb.WriteString("    '1: :->command' \\\n")
+	sb.WriteString("    '*:: :->args'\n\n")
+
+	sb.WriteString("  case $state in\n")
+	sb.WriteString("    command)\n")
+	sb.WriteString("      _alternative 'commands:command:_describe -t commands command commands' 'files:markdown file:__mdrs_markdown_files'\n")
+	sb.WriteString("      ;;\n")
+	sb.WriteString("    args)\n")
+	sb.WriteString("      case $words[1] in\n")
+	for _, word := range top {
+		subs := subCommands(commands, word)
+		if len(subs) == 0 {
+			sb.WriteString(fmt.Sprintf("        %s) __mdrs_%s ;;\n", word, word))
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("        %s)\n", word))
+		sb.WriteString("          if (( CURRENT == 2 )); then\n")
+		sb.WriteString("            local -a subcommands\n")
+		sb.WriteString("            subcommands=(\n")
+		for _, sub := range subs {
+			name := strings.Fields(sub.name)[1]
+			sb.WriteString(fmt.Sprintf("              %s\n", zshQuote(name+":"+strings.Replace(sub.doc, ":", "\\:", -1))))
+		}
+		sb.WriteString("            )\n")
+		sb.WriteString(fmt.Sprintf("            _describe -t commands '%s command' subcommands\n", word))
+		sb.WriteString("          else\n")
+		sb.WriteString("            shift words\n")
+		sb.WriteString("            (( CURRENT-- ))\n")
+		sb.WriteString("            case $words[1] in\n")
+		for _, sub := range subs {
+			name := strings.Fields(sub.name)[1]
+			sb.WriteString(fmt.Sprintf("              %s) __mdrs_%s_%s ;;\n", name, word, name))
+		}
+		sb.WriteString("            esac\n")
+		sb.WriteString("          fi\n")
+		sb.WriteString("          ;;\n")
+	}
+	sb.WriteString("        help) _describe -t commands command commands ;;\n")
+	// A file being viewed, the options may follow it
+	sb.WriteString(fmt.Sprintf("        *) __mdrs_%s ;;\n", commands[0].name))
+	sb.WriteString("      esac\n")
+	sb.WriteString("      ;;\n")
+	sb.WriteString("  esac\n")
+	sb.WriteString("}\n\n")
+	sb.WriteString("if [ \"$funcstack[1]\" = \"_mdrs\" ]; then\n")
+	sb.WriteString("  _mdrs \"$@\"\n")
+	sb.WriteString("else\n")
+	sb.WriteString("  compdef _mdrs mdrs\n")
+	sb.WriteString("fi\n")
+
+	return sb.String()
+}
+
+// zshFlagSpec returns the _arguments spec of a flag
+func zshFlagSpec(f completionFlag) string {
+	doc := strings.NewReplacer("[", "\\[", "]", "\\]", ":", "\\:").Replace(f.doc)
+	if !f.takesValue {
+		return zshQuote(fmt.Sprintf("%s[%s]", f.dashed(), doc))
+	}
+
+	action := " "
+	switch {
+	case f.files:
+		action = "_files"
+	case len(f.values) > 0:
+		action = "(" + strings.Join(f.values, " ") + ")"
+	}
+	return zshQuote(fmt.Sprintf("%s=[%s]:%s:%s", f.dashed(), doc, f.name, action))
+}
+
+// zshQuote quotes a word for zsh
+func zshQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+func fishCompletion(commands []completionCommand) string {
+	var sb strings.Builder
+	top := topCommands(commands)
+
+	sb.WriteString("# fish completion for mdrs, generated by \"mdrs completion fish\"\n")
+	sb.WriteString("# Save it as ~/.config/fish/completions/mdrs.fish, or load it with:\n")
+	sb.WriteString("#   mdrs completion fish | source\n\n")
+
+	// The command is the first word that isn't a flag or its value, a file
+	// being viewed
+	sb.WriteString("function __mdrs_command\n")
+	sb.WriteString("    set -l words (commandline -opc)\n")
+	sb.WriteString("    set -e words[1]\n")
+	sb.WriteString("    set -l skip 0\n")
+	sb.WriteString("    for i in (seq (count $words))\n")
+	sb.WriteString("        set -l word $words[$i]\n")
+	sb.WriteString("        if test $skip = 1\n")
+	sb.WriteString("            set skip 0\n")
+	sb.WriteString("            continue\n")
+	sb.WriteString("        end\n")
+	sb.WriteString("        switch $word\n")
+	sb.WriteString(fmt.Sprintf("            case %s\n", strings.Join(valueFlags(commands), " ")))
+	sb.WriteString("                set skip 1\n")
+	sb.WriteString("            case '-*'\n")
+	for _, word := range top {
+		if len(subCommands(commands, word)) > 0 {
+			sb.WriteString(fmt.Sprintf("            case %s\n", word))
+			sb.WriteString("                set -l next (math $i + 1)\n")
+			sb.WriteString("                if set -q words[$next]\n")
+			sb.WriteString(fmt.Sprintf("                    echo %s $words[$next]\n", word))
+			sb.WriteString("                else\n")
+			sb.WriteString(fmt.Sprintf("                    echo %s\n", word))
+			sb.WriteString("                end\n")
+			sb.WriteString("                return\n")
+		}
+	}
+	var simple []string
+	for _, word := range top {
+		if len(subCommands(commands, word)) == 0 {
+			simple = append(simple, word)
+		}
+	}
+	sb.WriteString(fmt.Sprintf("            case %s help\n", strings.Join(simple, " ")))
+	sb.WriteString("                echo $word\n")
+	sb.WriteString("                return\n")
+	sb.WriteString("            case '*'\n")
+	sb.WriteString(fmt.Sprintf("                echo %s\n", commands[0].name))
+	sb.WriteString("                return\n")
+	sb.WriteString("        end\n")
+	sb.WriteString("    end\n")
+	sb.WriteString("end\n\n")
+
+	sb.WriteString("function __mdrs_command_is\n")
+	sb.WriteString("    set -l command (__mdrs_command)\n")
+	sb.WriteString("    contains -- \"$command\" $argv\n")
+	sb.WriteString("end\n\n")
+
+	sb.WriteString("complete -c mdrs -f\n\n")
+
+	// The commands, before any
+	sb.WriteString("# Commands\n")
+	for _, word := range top {
+		doc := "Manage the " + word
+		for _, cmd := range commands {
+			if cmd.name == word {
+				doc = cmd.doc
+			}
+		}
+		sb.WriteString(fmt.Sprintf("complete -c mdrs -n '__mdrs_command_is \"\"' -a %s -d %s\n", word, fishQuote(doc)))
+	}
+	sb.WriteString("complete -c mdrs -n '__mdrs_command_is \"\"' -a help -d 'Show the help of a command'\n")
+	sb.WriteString(fmt.Sprintf("complete -c mdrs -n '__mdrs_command_is help' -a '%s'\n", strings.Join(top, " ")))
+	for _, word := range top {
+		for _, sub := range subCommands(commands, word) {
+			name := strings.Fields(sub.name)[1]
+			sb.WriteString(fmt.Sprintf("complete -c mdrs -n '__mdrs_command_is %s' -a %s -d %s\n", word, name, fishQuote(sub.doc)))
+		}
+	}
+
+	for i, cmd := range commands {
+		sb.WriteString(fmt.Sprintf("\n# %s\n", cmd.name))
+		condition := fmt.Sprintf("__mdrs_command_is \"%s\"", cmd.name)
+		if i == 0 {
+			// The options of the default command come before the file too
+			condition = fmt.Sprintf("__mdrs_command_is \"\" \"%s\"", cmd.name)
+		}
+		for _, f := range cmd.flags {
+			line := fmt.Sprintf("complete -c mdrs -n %s", fishQuote(condition))
+			if len(f.name) == 1 {
+				line += " -s " + f.name
+			} else {
+				line += " -l " + f.name
+			}
+			switch {
+			case f.files:
+				line += " -r -F"
+			case len(f.values) > 0:
+				line += fmt.Sprintf(" -x -a '%s'", strings.Join(f.values, " "))
+			case f.takesValue:
+				line += " -x"
+			}
+			if f.doc != "" {
+				line += " -d " + fishQuote(f.doc)
+			}
+			sb.WriteString(line + "\n")
+		}
+		switch {
+		case cmd.markdown:
+			sb.WriteString(fmt.Sprintf("complete -c mdrs -n %s -k -a '(__fish_complete_suffix .md; __fish_complete_suffix .markdown)'\n", fishQuote(condition)))
+		case len(cmd.words) > 0:
+			sb.WriteString(fmt.Sprintf("complete -c mdrs -n %s -a '%s'\n", fishQuote(condition), strings.Join(cmd.words, " ")))
+		}
+	}
+
+	return sb.String()
+}
+
+// fishQuote quotes a word for fish
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s) + "'"
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"io"
+	"strings"
+
+	"github.com/ulikunitz/xz"
+)
+
+// compression is a compression format of the documents, detected by its
+// magic bytes
+type compression struct {
+	name string
+	// detect tells whether the first bytes of a document are in this format
+	detect    func(header []byte) bool
+	extension string
+	// reader decompresses r, also giving the original file name when the
+	// format records it
+	reader func(r io.Reader) (io.Reader, string, error)
+}
+
+var compressions = []compression{
+	{
+		name:      "gzip",
+		detect:    hasMagic(0x1f, 0x8b),
+		extension: ".gz",
+		reader: func(r io.Reader) (io.Reader, string, error) {
+			zr, err := gzip.NewReader(r)
+			if err != nil {
+				return nil, "", err
+			}
+			return zr, zr.Name, nil
+		},
+	},
+	{
+		name:      "bzip2",
+		detect:    isBzip2,
+		extension: ".bz2",
+		reader: func(r io.Reader) (io.Reader, string, error) {
+			return bzip2.NewReader(r), "", nil
+		},
+	},
+	{
+		name:      "xz",
+		detect:    hasMagic(0xfd, '7', 'z', 'X', 'Z', 0x00),
+		extension: ".xz",
+		reader: func(r io.Reader) (io.Reader, string, error) {
+			zr, err := xz.NewReader(r)
+			return zr, "", err
+		},
+	},
+}
+
+// headerSize is the number of bytes read to detect the compression
+const headerSize = 10
+
+// hasMagic detects a format by the bytes it starts with
+func hasMagic(magic ...byte) func(header []byte) bool {
+	return func(header []byte) bool {
+		return bytes.HasPrefix(header, magic)
+	}
+}
+
+// isBzip2 detects bzip2 by its "BZh" magic, the level from 1 to 9 and the
+// magic of the first block, as "BZh" alone may start a text. An empty stream
+// has the end of stream magic instead of a block.
+func isBzip2(header []byte) bool {
+	if len(header) < 10 || !bytes.HasPrefix(header, []byte("BZh")) ||
+		header[3] < '1' || header[3] > '9' {
+		return false
+	}
+	block := header[4:10]
+	return bytes.Equal(block, []byte{0x31, 0x41, 0x59, 0x26, 0x53, 0x59}) ||
+		bytes.Equal(block, []byte{0x17, 0x72, 0x45, 0x38, 0x50, 0x90})
+}
+
+// decompressReader detects the compression of r by its magic bytes and
+// returns a reader of the decompressed content, along with the original file
+// name when known. The content that isn't compressed is returned as is.
+func decompressReader(r io.Reader) (io.Reader, string, error) {
+	br := bufio.NewReader(r)
+	// a short document is peeked whole, with an error
+	header, _ := br.Peek(headerSize)
+	for _, c := range compressions {
+		if c.detect(header) {
+			return c.reader(br)
+		}
+	}
+	return br, "", nil
+}
+
+// uncompressedName returns the name of a file without the extension of its
+// compression, like README.md for README.md.gz
+func uncompressedName(name string) string {
+	for _, c := range compressions {
+		if strings.HasSuffix(strings.ToLower(name), c.extension) {
+			return name[:len(name)-len(c.extension)]
+		}
+	}
+	return name
+}
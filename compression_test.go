@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"testing"
+
+	"github.com/ulikunitz/xz"
+)
+
+const compressedText = "# Title\n\ntext\n"
+
+// bzip2Text is compressedText compressed with bzip2 -9, the standard library
+// having no bzip2 writer
+var bzip2Text = []byte{
+	0x42, 0x5a, 0x68, 0x39, 0x31, 0x41, 0x59, 0x26, 0x53, 0x59, 0x9d, 0x10,
+	0xc4, 0x64, 0x00, 0x00, 0x02, 0x53, 0x80, 0x00, 0x10, 0x48, 0x00, 0x04,
+	0x00, 0x02, 0x24, 0x04, 0x40, 0x20, 0x00, 0x22, 0x1a, 0x3d, 0x43, 0x42,
+	0x0c, 0x98, 0x83, 0xa8, 0x47, 0x1a, 0x9e, 0x37, 0xe2, 0xee, 0x48, 0xa7,
+	0x0a, 0x12, 0x13, 0xa2, 0x18, 0x8c, 0x80,
+}
+
+func gzipText(t *testing.T, name string) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Name = name
+	if _, err := zw.Write([]byte(compressedText)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func xzText(t *testing.T) []byte {
+	var buf bytes.Buffer
+	zw, err := xz.NewWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := zw.Write([]byte(compressedText)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecompressReader(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    []byte
+		expected string
+		fileName string
+		error    bool
+	}{
+		{name: "gzip", input: gzipText(t, ""), expected: compressedText},
+		{name: "gzip with a name", input: gzipText(t, "doc.md"), expected: compressedText, fileName: "doc.md"},
+		{name: "bzip2", input: bzip2Text, expected: compressedText},
+		{name: "empty bzip2", input: []byte("BZh9\x17\x72\x45\x38\x50\x90\x00\x00\x00\x00"), expected: ""},
+		{name: "xz", input: xzText(t), expected: compressedText},
+		{name: "plain text", input: []byte(compressedText), expected: compressedText},
+		{name: "plain text starting like bzip2", input: []byte("BZhello\n"), expected: "BZhello\n"},
+		{name: "plain text with the bzip2 level", input: []byte("BZh9 is a level\n"), expected: "BZh9 is a level\n"},
+		{name: "empty", input: nil, expected: ""},
+		{name: "shorter than the magic bytes", input: []byte{0x1f}, expected: "\x1f"},
+		{name: "broken gzip", input: []byte{0x1f, 0x8b, 0x00}, error: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r, fileName, err := decompressReader(bytes.NewReader(test.input))
+			var data []byte
+			if err == nil {
+				data, err = ioutil.ReadAll(r)
+			}
+			if test.error {
+				if err == nil {
+					t.Errorf("expected an error, got %q", data)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != test.expected {
+				t.Errorf("got %q, expected %q", data, test.expected)
+			}
+			if fileName != test.fileName {
+				t.Errorf("got the name %q, expected %q", fileName, test.fileName)
+			}
+		})
+	}
+}
+
+func TestUncompressedName(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"README.md.gz", "README.md"},
+		{"README.md.GZ", "README.md"},
+		{"doc.md.bz2", "doc.md"},
+		{"doc.md.xz", "doc.md"},
+		{"doc.md", "doc.md"},
+		{"gz", "gz"},
+	}
+
+	for _, test := range tests {
+		if got := uncompressedName(test.name); got != test.expected {
+			t.Errorf("%q: got %q, expected %q", test.name, got, test.expected)
+		}
+	}
+}
@@ -6,77 +6,127 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strconv"
 	"strings"
 
 	"github.com/MichaelMure/go-term-markdown"
 )
 
-// Config holds the configuration for mdrs
+// Config holds the configuration for mdrs. The doc tags describe the settings
+// in the generated config files.
 type Config struct {
-	Colors     ColorConfig     `json:"colors"`
-	Keybindings KeybindingConfig `json:"keybindings"`
+	Theme       string           `json:"theme" doc:"Built-in color theme: default, dracula or solarized-dark, the colors set explicitly take precedence over it"`
+	Colors      ColorConfig      `json:"colors" doc:"Colors of the markdown elements, as hex values like \"#ff8700\""`
+	Keybindings KeybindingConfig `json:"keybindings" doc:"Keys of the actions, each action accepting several keys"`
+
+	// Layout
+	MaxWidth int  `json:"max_width" doc:"Maximum width of the text column, 0 for the full terminal width"`
+	Center   bool `json:"center" doc:"Center the text column horizontally"`
+
+	// Status line
+	StatusFormat string `json:"status_format" doc:"Status line format, with the placeholders {file}, {line}, {lines}, {percent}, {section} and {search}"`
+	HideStatus   bool   `json:"hide_status" doc:"Start with the status line hidden"`
+
+	// Rendering
+	DiskCache bool `json:"disk_cache" doc:"Keep the renderings of the slow documents on disk, so that reopening them is instant"`
 }
 
 // KeybindingConfig holds custom keybinding settings
 type KeybindingConfig struct {
 	// Navigation keys
-	ScrollUp       []string `json:"scroll_up"`
-	ScrollDown     []string `json:"scroll_down"`
-	ScrollLeft     []string `json:"scroll_left"`
-	ScrollRight    []string `json:"scroll_right"`
-	PageUp         []string `json:"page_up"`
-	PageDown       []string `json:"page_down"`
-	GoToTop        []string `json:"go_to_top"`
-	GoToBottom     []string `json:"go_to_bottom"`
-	
+	ScrollUp    []string `json:"scroll_up" doc:"Move up"`
+	ScrollDown  []string `json:"scroll_down" doc:"Move down"`
+	ScrollLeft  []string `json:"scroll_left" doc:"Move left"`
+	ScrollRight []string `json:"scroll_right" doc:"Move right"`
+	PageUp      []string `json:"page_up" doc:"Page up"`
+	PageDown    []string `json:"page_down" doc:"Page down"`
+	GoToTop     []string `json:"go_to_top" doc:"Go to top"`
+	GoToBottom  []string `json:"go_to_bottom" doc:"Go to bottom"`
+	CycleWidth  []string `json:"cycle_width" doc:"Cycle content width"`
+
+	// Marks keys, followed by the name of the mark (a-z)
+	SetMark    []string `json:"set_mark" doc:"Set a mark, followed by its name from a to z"`
+	JumpToMark []string `json:"jump_to_mark" doc:"Jump to a mark, followed by its name from a to z"`
+	ShowMarks  []string `json:"show_marks" doc:"List marks"`
+
 	// Search keys
-	StartSearch    []string `json:"start_search"`
-	NextMatch      []string `json:"next_match"`
-	PrevMatch      []string `json:"prev_match"`
-	ClearSearch    []string `json:"clear_search"`
-	
+	StartSearch []string `json:"start_search" doc:"Start search"`
+	NextMatch   []string `json:"next_match" doc:"Next match"`
+	PrevMatch   []string `json:"prev_match" doc:"Previous match"`
+	ClearSearch []string `json:"clear_search" doc:"Clear search"`
+
 	// General keys
-	Quit           []string `json:"quit"`
-	ShowHelp       []string `json:"show_help"`
+	Quit         []string `json:"quit" doc:"Quit"`
+	ShowHelp     []string `json:"show_help" doc:"Show the help"`
+	ToggleStatus []string `json:"toggle_status" doc:"Toggle status line"`
+
+	// Key sequences
+	Leader     string `json:"leader" doc:"Key standing for <leader> in key sequences"`
+	KeyTimeout int    `json:"key_timeout" doc:"Milliseconds to wait for the next key of a sequence, -1 to wait forever"`
+
+	// Keys of the other views, only active in that view
+	Help   HelpKeybindings   `json:"help" doc:"Keys of the help popup"`
+	Marks  PopupKeybindings  `json:"marks" doc:"Keys of the marks popup"`
+	Search SearchKeybindings `json:"search" doc:"Keys of the search input"`
+}
+
+// HelpKeybindings holds the keybindings of the help popup
+type HelpKeybindings struct {
+	Close      []string `json:"close" doc:"Close the popup"`
+	ScrollUp   []string `json:"scroll_up" doc:"Scroll the help up"`
+	ScrollDown []string `json:"scroll_down" doc:"Scroll the help down"`
+	PageUp     []string `json:"page_up" doc:"Scroll the help a page up"`
+	PageDown   []string `json:"page_down" doc:"Scroll the help a page down"`
+}
+
+// PopupKeybindings holds the keybindings of a popup
+type PopupKeybindings struct {
+	Close []string `json:"close" doc:"Close the popup"`
+}
+
+// SearchKeybindings holds the keybindings of the search input
+type SearchKeybindings struct {
+	Execute []string `json:"execute" doc:"Execute the search"`
+	Cancel  []string `json:"cancel" doc:"Cancel the search"`
 }
 
 // ColorConfig holds color settings for markdown elements
 type ColorConfig struct {
 	// Headings
-	Heading1       string `json:"heading1"`
-	Heading2       string `json:"heading2"`
-	Heading3       string `json:"heading3"`
-	Heading4       string `json:"heading4"`
-	Heading5       string `json:"heading5"`
-	Heading6       string `json:"heading6"`
-	
+	Heading1 string `json:"heading1" doc:"Level 1 headings"`
+	Heading2 string `json:"heading2" doc:"Level 2 headings"`
+	Heading3 string `json:"heading3" doc:"Level 3 headings"`
+	Heading4 string `json:"heading4" doc:"Level 4 headings"`
+	Heading5 string `json:"heading5" doc:"Level 5 headings"`
+	Heading6 string `json:"heading6" doc:"Level 6 headings"`
+
 	// Text elements
-	Bold           string `json:"bold"`
-	Italic         string `json:"italic"`
-	Strikethrough  string `json:"strikethrough"`
-	Link           string `json:"link"`
-	LinkURL        string `json:"link_url"`
-	
+	Bold          string `json:"bold" doc:"Bold text"`
+	Italic        string `json:"italic" doc:"Italic text"`
+	Strikethrough string `json:"strikethrough" doc:"Strikethrough text"`
+	Link          string `json:"link" doc:"Link text"`
+	LinkURL       string `json:"link_url" doc:"Link URL"`
+
 	// Code
-	Code           string `json:"code"`
-	CodeBlock      string `json:"code_block"`
-	CodeBlockBg    string `json:"code_block_bg"`
-	
+	Code        string `json:"code" doc:"Inline code"`
+	CodeBlock   string `json:"code_block" doc:"Code blocks"`
+	CodeBlockBg string `json:"code_block_bg" doc:"Background of the code blocks"`
+
 	// Lists
-	ListMarker     string `json:"list_marker"`
-	TaskChecked    string `json:"task_checked"`
-	TaskUnchecked  string `json:"task_unchecked"`
-	
+	ListMarker    string `json:"list_marker" doc:"List markers"`
+	TaskChecked   string `json:"task_checked" doc:"Checked tasks"`
+	TaskUnchecked string `json:"task_unchecked" doc:"Unchecked tasks"`
+
 	// Quotes and tables
-	BlockQuote     string `json:"blockquote"`
-	TableHeader    string `json:"table_header"`
-	TableRow       string `json:"table_row"`
-	TableBorder    string `json:"table_border"`
-	
+	BlockQuote  string `json:"blockquote" doc:"Block quotes"`
+	TableHeader string `json:"table_header" doc:"Table headers"`
+	TableRow    string `json:"table_row" doc:"Table rows"`
+	TableBorder string `json:"table_border" doc:"Table borders"`
+
 	// Search highlighting (for our search feature)
-	SearchCurrent  string `json:"search_current"`
-	SearchMatch    string `json:"search_match"`
+	SearchCurrent string `json:"search_current" doc:"Current search match"`
+	SearchMatch   string `json:"search_match" doc:"Other search matches"`
 }
 
 // DefaultConfig returns the default configuration
@@ -90,184 +140,554 @@ func DefaultConfig() *Config {
 			ScrollRight: []string{"l", "o", "Right"},
 			PageUp:      []string{"PageUp"},
 			PageDown:    []string{"PageDown", "Space"},
-			GoToTop:     []string{"g"},
+			GoToTop:     []string{"g g"},
 			GoToBottom:  []string{"G"},
-			
+			CycleWidth:  []string{"w"},
+
+			// Marks
+			SetMark:    []string{"m"},
+			JumpToMark: []string{"'"},
+			ShowMarks:  []string{"M"},
+
 			// Search
 			StartSearch: []string{"/", "C-f"},
 			NextMatch:   []string{"n"},
 			PrevMatch:   []string{"N"},
 			ClearSearch: []string{"Escape"},
-			
+
 			// General
-			Quit:        []string{"q", "C-c"},
-			ShowHelp:    []string{"?"},
+			Quit:         []string{"q", "C-c"},
+			ShowHelp:     []string{"?"},
+			ToggleStatus: []string{"s"},
+
+			// Key sequences
+			Leader:     "\\",
+			KeyTimeout: 1000,
+
+			// Other views
+			Help: HelpKeybindings{
+				Close:      []string{"Escape", "Enter", "Space", "q", "?"},
+				ScrollUp:   []string{"k", "i", "Up", "C-p"},
+				ScrollDown: []string{"j", "e", "Down", "C-n"},
+				PageUp:     []string{"PageUp"},
+				PageDown:   []string{"PageDown"},
+			},
+			Marks: PopupKeybindings{Close: []string{"Escape", "Enter", "Space", "q"}},
+			Search: SearchKeybindings{
+				Execute: []string{"Enter"},
+				Cancel:  []string{"Escape", "C-c", "C-g"},
+			},
 		},
 		Colors: ColorConfig{
 			// Headings - blue shades
-			Heading1:       "#00d7ff",
-			Heading2:       "#00afff",
-			Heading3:       "#0087ff",
-			Heading4:       "#005fff",
-			Heading5:       "#0037ff",
-			Heading6:       "#001fff",
-			
+			Heading1: "#00d7ff",
+			Heading2: "#00afff",
+			Heading3: "#0087ff",
+			Heading4: "#005fff",
+			Heading5: "#0037ff",
+			Heading6: "#001fff",
+
 			// Text elements
-			Bold:           "#ffffff",
-			Italic:         "#87ff00",
-			Strikethrough:  "#808080",
-			Link:           "#00ffff",
-			LinkURL:        "#0087af",
-			
+			Bold:          "#ffffff",
+			Italic:        "#87ff00",
+			Strikethrough: "#808080",
+			Link:          "#00ffff",
+			LinkURL:       "#0087af",
+
 			// Code
-			Code:           "#ffff00",
-			CodeBlock:      "#d7ff00",
-			CodeBlockBg:    "#262626",
-			
+			Code:        "#ffff00",
+			CodeBlock:   "#d7ff00",
+			CodeBlockBg: "#262626",
+
 			// Lists
-			ListMarker:     "#ff8700",
-			TaskChecked:    "#00ff00",
-			TaskUnchecked:  "#ff0000",
-			
+			ListMarker:    "#ff8700",
+			TaskChecked:   "#00ff00",
+			TaskUnchecked: "#ff0000",
+
 			// Quotes and tables
-			BlockQuote:     "#808080",
-			TableHeader:    "#ffff00",
-			TableRow:       "#ffffff",
-			TableBorder:    "#808080",
-			
+			BlockQuote:  "#808080",
+			TableHeader: "#ffff00",
+			TableRow:    "#ffffff",
+			TableBorder: "#808080",
+
 			// Search
-			SearchCurrent:  "#ffff00",
-			SearchMatch:    "#ff8700",
+			SearchCurrent: "#ffff00",
+			SearchMatch:   "#ff8700",
 		},
+		StatusFormat: DefaultStatusFormat,
 	}
 }
 
-// LoadConfig loads configuration from the config file
-func LoadConfig() (*Config, error) {
-	configPath := getConfigPath()
-	
-	// Check if config file exists
-	if _, err := os.Stat(configPath); os.IsNotExist(err) {
-		// Create default config file
-		config := DefaultConfig()
-		if err := config.Save(); err != nil {
-			return config, nil // Return default config even if save fails
+// configSources tells which config file each value of the configuration
+// comes from, by JSON path like "colors.heading1"
+type configSources map[string]string
+
+// of returns the source of a value of the configuration
+func (s configSources) of(path string) string {
+	if source, ok := s[path]; ok {
+		return source
+	}
+	return "default"
+}
+
+// LoadConfig loads the configuration for the documents of a directory,
+// layering the config files returned by getConfigPaths on top of the
+// defaults. Nothing is written, a missing config file just means the
+// defaults apply.
+func LoadConfig(dir string) (*Config, error) {
+	config, _, err := loadConfigSources(dir)
+	return config, err
+}
+
+// loadConfigSources loads the configuration like LoadConfig, also telling
+// where each value comes from
+func loadConfigSources(dir string) (*Config, configSources, error) {
+	// A broken config file doesn't prevent the others from applying
+	config := DefaultConfig()
+	sources := make(configSources)
+	var errs []string
+
+	// A config file given explicitly must exist, the other layers still apply
+	if explicitConfigPath() {
+		if _, err := os.Stat(getConfigPath()); os.IsNotExist(err) {
+			errs = append(errs, fmt.Sprintf("config file not found: %s", getConfigPath()))
+		}
+	}
+
+	for _, path := range getConfigPaths(dir) {
+		if err := config.load(path, sources); err != nil {
+			errs = append(errs, err.Error())
 		}
-		return config, nil
 	}
-	
-	// Read config file
-	data, err := ioutil.ReadFile(configPath)
+
+	// The environment comes after the files, for the places where a file
+	// can't be dropped in, and the command line last
+	for _, p := range config.loadEnv(sources) {
+		errs = append(errs, p.String())
+	}
+
+	config.loadCommandLine(sources)
+
+	if err := config.applyTheme(sources); err != nil {
+		errs = append(errs, err.Error())
+	}
+	if len(errs) > 0 {
+		return config, sources, fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+
+	return config, sources, nil
+}
+
+// load reads a config file on top of the current configuration, see merge
+func (c *Config) load(path string, sources configSources) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	jsonData, _, err := decodeConfig(path, data)
 	if err != nil {
-		return DefaultConfig(), fmt.Errorf("failed to read config file: %w", err)
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	var values map[string]interface{}
+	if err := json.Unmarshal(jsonData, &values); err != nil {
+		return fmt.Errorf("failed to parse config file %s: expected an object", path)
+	}
+
+	err = c.merge(values, func(valuePath string) {
+		sources[valuePath] = path
+	})
+	if err != nil {
+		return fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// applyTheme sets the colors of the theme, except the ones set explicitly
+func (c *Config) applyTheme(sources configSources) error {
+	if c.Theme == "" {
+		return nil
+	}
+
+	t, err := themeByName(c.Theme)
+	if err != nil {
+		return fmt.Errorf("%s: %w", sources.of("theme"), err)
+	}
+
+	colors := reflect.ValueOf(&c.Colors).Elem()
+	themeColors := reflect.ValueOf(t.colors)
+	for i := 0; i < colors.NumField(); i++ {
+		path := "colors." + jsonName(colors.Type().Field(i))
+		if _, set := sources[path]; set {
+			continue
+		}
+		colors.Field(i).Set(themeColors.Field(i))
+		sources[path] = "theme " + t.name
+	}
+	return nil
+}
+
+// configTypeError is a value of a config file of the wrong type
+type configTypeError struct {
+	path     string
+	expected string
+	got      string
+}
+
+func (e *configTypeError) Error() string {
+	return fmt.Sprintf("%s: expected %s, got %s", e.path, e.expected, e.got)
+}
+
+// configTypeErrors are all the values of a config file of the wrong type
+type configTypeErrors []*configTypeError
+
+func (errs configTypeErrors) Error() string {
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, ", ")
+}
+
+// merge sets the values of a config file, in their generic JSON form, on top
+// of the config:
+//   - a missing or null value keeps the current value
+//   - any other value replaces it, even an empty list, so that [] unbinds
+//     the keys of an action
+//   - the values of the nested objects are merged the same way
+//
+// The values of the wrong type are skipped and reported in the returned
+// error. set is called with the path of each value set, like
+// "colors.heading1".
+func (c *Config) merge(values map[string]interface{}, set func(path string)) error {
+	var errs configTypeErrors
+	mergeStruct(reflect.ValueOf(c).Elem(), values, "", set, &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func mergeStruct(v reflect.Value, values map[string]interface{}, path string, set func(path string), errs *configTypeErrors) {
+	for i := 0; i < v.NumField(); i++ {
+		name := jsonName(v.Type().Field(i))
+		value, ok := values[name]
+		if !ok || value == nil {
+			continue
+		}
+
+		field := v.Field(i)
+		fieldPath := joinPath(path, name)
+
+		if field.Kind() == reflect.Struct {
+			object, ok := value.(map[string]interface{})
+			if !ok {
+				*errs = append(*errs, &configTypeError{path: fieldPath, expected: "an object", got: describeJSON(value)})
+				continue
+			}
+			mergeStruct(field, object, fieldPath, set, errs)
+			continue
+		}
+
+		// Decode the value with the JSON rules of the field type
+		data, err := json.Marshal(value)
+		if err == nil {
+			decoded := reflect.New(field.Type())
+			err = json.Unmarshal(data, decoded.Interface())
+			if err == nil {
+				field.Set(decoded.Elem())
+				if set != nil {
+					set(fieldPath)
+				}
+				continue
+			}
+		}
+		*errs = append(*errs, &configTypeError{path: fieldPath, expected: describeType(field.Type()), got: describeJSON(value)})
+	}
+}
+
+// describeType names the JSON type expected for a Go type
+func describeType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "an integer"
+	case reflect.String:
+		return "a string"
+	case reflect.Slice:
+		return "a list of " + strings.TrimPrefix(strings.TrimPrefix(describeType(t.Elem()), "a "), "an ") + "s"
+	case reflect.Struct, reflect.Map:
+		return "an object"
+	default:
+		return t.String()
 	}
-	
-	// Parse JSON config
-	var config Config
-	if err := json.Unmarshal(data, &config); err != nil {
-		return DefaultConfig(), fmt.Errorf("failed to parse config file: %w", err)
+}
+
+// describeJSON names the type of a generic JSON value
+func describeJSON(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return fmt.Sprintf("the boolean %v", v)
+	case float64:
+		return fmt.Sprintf("the number %v", v)
+	case string:
+		return fmt.Sprintf("the string %q", v)
+	case []interface{}:
+		return "a list"
+	case map[string]interface{}:
+		return "an object"
+	default:
+		return fmt.Sprintf("%v", v)
 	}
-	
-	// Fill in any missing values with defaults
-	config.fillDefaults()
-	
-	return &config, nil
 }
 
-// Save saves the configuration to file
-func (c *Config) Save() error {
-	configPath := getConfigPath()
+// Save saves the configuration to a JSON file. Only the values differing from
+// the defaults are written, so that changes of the defaults reach the user.
+func (c *Config) Save(configPath string) error {
 	configDir := filepath.Dir(configPath)
-	
+
 	// Create config directory if it doesn't exist
 	if err := os.MkdirAll(configDir, 0755); err != nil {
 		return fmt.Errorf("failed to create config directory: %w", err)
 	}
-	
+
+	overrides, err := c.overrides()
+	if err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
+	}
+
 	// Marshal config to JSON with indentation
-	data, err := json.MarshalIndent(c, "", "  ")
+	data, err := json.MarshalIndent(overrides, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal config: %w", err)
 	}
-	
+	data = append(data, '\n')
+
 	// Write config file
 	if err := ioutil.WriteFile(configPath, data, 0644); err != nil {
 		return fmt.Errorf("failed to write config file: %w", err)
 	}
-	
+
 	return nil
 }
 
-// fillDefaults fills in any missing configuration values with defaults
-func (c *Config) fillDefaults() {
-	defaults := DefaultConfig()
-	
-	// Fill in keybindings if missing
-	if c.Keybindings.ScrollUp == nil { c.Keybindings.ScrollUp = defaults.Keybindings.ScrollUp }
-	if c.Keybindings.ScrollDown == nil { c.Keybindings.ScrollDown = defaults.Keybindings.ScrollDown }
-	if c.Keybindings.ScrollLeft == nil { c.Keybindings.ScrollLeft = defaults.Keybindings.ScrollLeft }
-	if c.Keybindings.ScrollRight == nil { c.Keybindings.ScrollRight = defaults.Keybindings.ScrollRight }
-	if c.Keybindings.PageUp == nil { c.Keybindings.PageUp = defaults.Keybindings.PageUp }
-	if c.Keybindings.PageDown == nil { c.Keybindings.PageDown = defaults.Keybindings.PageDown }
-	if c.Keybindings.GoToTop == nil { c.Keybindings.GoToTop = defaults.Keybindings.GoToTop }
-	if c.Keybindings.GoToBottom == nil { c.Keybindings.GoToBottom = defaults.Keybindings.GoToBottom }
-	if c.Keybindings.StartSearch == nil { c.Keybindings.StartSearch = defaults.Keybindings.StartSearch }
-	if c.Keybindings.NextMatch == nil { c.Keybindings.NextMatch = defaults.Keybindings.NextMatch }
-	if c.Keybindings.PrevMatch == nil { c.Keybindings.PrevMatch = defaults.Keybindings.PrevMatch }
-	if c.Keybindings.ClearSearch == nil { c.Keybindings.ClearSearch = defaults.Keybindings.ClearSearch }
-	if c.Keybindings.Quit == nil { c.Keybindings.Quit = defaults.Keybindings.Quit }
-	if c.Keybindings.ShowHelp == nil { c.Keybindings.ShowHelp = defaults.Keybindings.ShowHelp }
-	
-	if c.Colors.Heading1 == "" { c.Colors.Heading1 = defaults.Colors.Heading1 }
-	if c.Colors.Heading2 == "" { c.Colors.Heading2 = defaults.Colors.Heading2 }
-	if c.Colors.Heading3 == "" { c.Colors.Heading3 = defaults.Colors.Heading3 }
-	if c.Colors.Heading4 == "" { c.Colors.Heading4 = defaults.Colors.Heading4 }
-	if c.Colors.Heading5 == "" { c.Colors.Heading5 = defaults.Colors.Heading5 }
-	if c.Colors.Heading6 == "" { c.Colors.Heading6 = defaults.Colors.Heading6 }
-	
-	if c.Colors.Bold == "" { c.Colors.Bold = defaults.Colors.Bold }
-	if c.Colors.Italic == "" { c.Colors.Italic = defaults.Colors.Italic }
-	if c.Colors.Strikethrough == "" { c.Colors.Strikethrough = defaults.Colors.Strikethrough }
-	if c.Colors.Link == "" { c.Colors.Link = defaults.Colors.Link }
-	if c.Colors.LinkURL == "" { c.Colors.LinkURL = defaults.Colors.LinkURL }
-	
-	if c.Colors.Code == "" { c.Colors.Code = defaults.Colors.Code }
-	if c.Colors.CodeBlock == "" { c.Colors.CodeBlock = defaults.Colors.CodeBlock }
-	if c.Colors.CodeBlockBg == "" { c.Colors.CodeBlockBg = defaults.Colors.CodeBlockBg }
-	
-	if c.Colors.ListMarker == "" { c.Colors.ListMarker = defaults.Colors.ListMarker }
-	if c.Colors.TaskChecked == "" { c.Colors.TaskChecked = defaults.Colors.TaskChecked }
-	if c.Colors.TaskUnchecked == "" { c.Colors.TaskUnchecked = defaults.Colors.TaskUnchecked }
-	
-	if c.Colors.BlockQuote == "" { c.Colors.BlockQuote = defaults.Colors.BlockQuote }
-	if c.Colors.TableHeader == "" { c.Colors.TableHeader = defaults.Colors.TableHeader }
-	if c.Colors.TableRow == "" { c.Colors.TableRow = defaults.Colors.TableRow }
-	if c.Colors.TableBorder == "" { c.Colors.TableBorder = defaults.Colors.TableBorder }
-	
-	if c.Colors.SearchCurrent == "" { c.Colors.SearchCurrent = defaults.Colors.SearchCurrent }
-	if c.Colors.SearchMatch == "" { c.Colors.SearchMatch = defaults.Colors.SearchMatch }
-}
-
-// getConfigPath returns the path to the config file
+// overrides returns the values of the config differing from the defaults, in
+// their JSON form
+func (c *Config) overrides() (map[string]interface{}, error) {
+	values, err := toJSONObject(c)
+	if err != nil {
+		return nil, err
+	}
+	defaults, err := toJSONObject(DefaultConfig())
+	if err != nil {
+		return nil, err
+	}
+	return diffJSONObjects(values, defaults), nil
+}
+
+// toJSONObject converts a struct to its generic JSON form
+func toJSONObject(v interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var result map[string]interface{}
+	err = json.Unmarshal(data, &result)
+	return result, err
+}
+
+// diffJSONObjects returns the values differing from the defaults, going down
+// into nested objects
+func diffJSONObjects(values, defaults map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{})
+	for key, value := range values {
+		object, isObject := value.(map[string]interface{})
+		defaultObject, defaultIsObject := defaults[key].(map[string]interface{})
+
+		if isObject && defaultIsObject {
+			if diff := diffJSONObjects(object, defaultObject); len(diff) > 0 {
+				result[key] = diff
+			}
+		} else if !reflect.DeepEqual(value, defaults[key]) {
+			result[key] = value
+		}
+	}
+	return result
+}
+
+// isCustom tells if the keys of an action differ from the default ones. The
+// action is named after its JSON path in the keybindings, like "help.close".
+func (k *KeybindingConfig) isCustom(action string) bool {
+	value := reflect.ValueOf(*k)
+	defaults := reflect.ValueOf(DefaultConfig().Keybindings)
+
+	for _, name := range strings.Split(action, ".") {
+		if value.Kind() != reflect.Struct {
+			return false
+		}
+		field, found := fieldByJSONName(value.Type(), name)
+		if !found {
+			return false
+		}
+		value = value.FieldByIndex(field.Index)
+		defaults = defaults.FieldByIndex(field.Index)
+	}
+
+	return !reflect.DeepEqual(value.Interface(), defaults.Interface())
+}
+
+// configFlag is the config file given on the command line, if any
+var configFlag string
+
+// getConfigPath returns the path to the user config file: the one given with
+// --config or $MDRS_CONFIG, or else the one of getUserConfigPath.
 func getConfigPath() string {
-	homeDir, err := os.UserHomeDir()
+	if configFlag != "" {
+		return configFlag
+	}
+	if path := os.Getenv("MDRS_CONFIG"); path != "" {
+		return path
+	}
+	return getUserConfigPath()
+}
+
+// getUserConfigPath returns the path to the config file in the mdrs directory
+// of $XDG_CONFIG_HOME (~/.config by default): config.json, config.yaml or
+// config.toml, config.json if there is none yet. It returns an empty string if
+// the home directory is unknown.
+func getUserConfigPath() string {
+	dir := getUserConfigDir()
+	if dir == "" {
+		return ""
+	}
+	if path := findConfigFile(dir, "config"); path != "" {
+		return path
+	}
+	return filepath.Join(dir, "config.json")
+}
+
+// getUserConfigDir returns the mdrs directory of $XDG_CONFIG_HOME, or an empty
+// string if the home directory is unknown
+func getUserConfigDir() string {
+	// Relative paths are invalid in the XDG variables
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if !filepath.IsAbs(configHome) {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		configHome = filepath.Join(homeDir, ".config")
+	}
+	return filepath.Join(configHome, "mdrs")
+}
+
+// findConfigFile returns the config file of a directory with the given base
+// name and the extension of one of the config formats, or an empty string if
+// there is none
+func findConfigFile(dir string, base string) string {
+	for _, name := range configFileNames(base) {
+		path := filepath.Join(dir, name)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+	}
+	return ""
+}
+
+// explicitConfigPath tells if the user config file was chosen explicitly
+func explicitConfigPath() bool {
+	return configFlag != "" || os.Getenv("MDRS_CONFIG") != ""
+}
+
+// getSystemConfigPaths returns the existing system-wide config files found in
+// $XDG_CONFIG_DIRS (/etc/xdg by default), the most important one last
+func getSystemConfigPaths() []string {
+	configDirs := os.Getenv("XDG_CONFIG_DIRS")
+	if configDirs == "" {
+		configDirs = "/etc/xdg"
+	}
+
+	// The directories come in order of importance, the first one wins
+	dirs := filepath.SplitList(configDirs)
+
+	var paths []string
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if !filepath.IsAbs(dirs[i]) {
+			continue
+		}
+		if path := findConfigFile(filepath.Join(dirs[i], "mdrs"), "config"); path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// projectConfigName is the base name of the project config files, like
+// .mdrs.json or .mdrs.yaml
+const projectConfigName = ".mdrs"
+
+// findProjectConfig returns the closest project config file in the directory
+// or one of its parents, or an empty string if there is none
+func findProjectConfig(dir string) string {
+	dir, err := filepath.Abs(dir)
 	if err != nil {
-		// Fallback to current directory
-		return "mdrs-config.json"
+		return ""
+	}
+
+	for {
+		if path := findConfigFile(dir, projectConfigName); path != "" {
+			return path
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
 	}
-	return filepath.Join(homeDir, ".config", "mdrs", "config.json")
+}
+
+// getConfigPaths returns the existing config files for the documents of a
+// directory, from the least to the most important:
+//   - the system-wide config files
+//   - the user config file
+//   - the project config file
+//   - the config file given with --config or $MDRS_CONFIG, which replaces the
+//     user config file
+func getConfigPaths(dir string) []string {
+	exists := func(path string) bool {
+		_, err := os.Stat(path)
+		return path != "" && err == nil
+	}
+
+	paths := getSystemConfigPaths()
+	if !explicitConfigPath() && exists(getUserConfigPath()) {
+		paths = append(paths, getUserConfigPath())
+	}
+	if project := findProjectConfig(dir); project != "" {
+		paths = append(paths, project)
+	}
+	if explicitConfigPath() && exists(getConfigPath()) {
+		paths = append(paths, getConfigPath())
+	}
+	return paths
 }
 
 // hexToANSI converts a hex color to the nearest ANSI 256 color
 func hexToANSI(hex string) (int, error) {
 	// Remove # prefix if present
 	hex = strings.TrimPrefix(hex, "#")
-	
+
 	// Parse hex color
 	if len(hex) != 6 {
 		return 0, fmt.Errorf("invalid hex color: %s", hex)
 	}
-	
+
 	r, err := strconv.ParseInt(hex[0:2], 16, 64)
 	if err != nil {
 		return 0, err
@@ -280,7 +700,7 @@ func hexToANSI(hex string) (int, error) {
 	if err != nil {
 		return 0, err
 	}
-	
+
 	// Convert to ANSI 256 color
 	// This is a simplified conversion - more sophisticated algorithms exist
 	if r == g && g == b {
@@ -291,14 +711,14 @@ func hexToANSI(hex string) (int, error) {
 		if r > 248 {
 			return 231, nil
 		}
-		return int(232 + ((r-8)/10)), nil
+		return int(232 + ((r - 8) / 10)), nil
 	}
-	
+
 	// Color cube (6x6x6)
 	r = (r * 5) / 255
 	g = (g * 5) / 255
 	b = (b * 5) / 255
-	
+
 	return int(16 + (36 * r) + (6 * g) + b), nil
 }
 
@@ -307,13 +727,13 @@ func (c *ColorConfig) GetANSIColor(hex string) string {
 	if hex == "" {
 		return ""
 	}
-	
+
 	colorCode, err := hexToANSI(hex)
 	if err != nil {
 		// Return default color on error
 		return "\033[0m"
 	}
-	
+
 	return fmt.Sprintf("\033[38;5;%dm", colorCode)
 }
 
@@ -322,12 +742,12 @@ func (c *ColorConfig) GetANSIBackground(hex string) string {
 	if hex == "" {
 		return ""
 	}
-	
+
 	colorCode, err := hexToANSI(hex)
 	if err != nil {
 		return ""
 	}
-	
+
 	return fmt.Sprintf("\033[48;5;%dm", colorCode)
 }
 
@@ -336,14 +756,20 @@ func (c *Config) GetMarkdownOptions() []markdown.Options {
 	opts := []markdown.Options{
 		markdown.WithImageDithering(markdown.DitheringWithBlocks),
 	}
-	
+
 	// Note: The go-term-markdown library may not support all color customizations
 	// We'll need to work with what's available in the API
 	// This is a placeholder for when we integrate with the actual markdown renderer
-	
+
 	return opts
 }
 
+// MarkdownOptionsKey describes the settings the markdown rendering depends on,
+// to tell apart the renderings of a document with different settings
+func (c *Config) MarkdownOptionsKey() string {
+	return "dithering=blocks"
+}
+
 // ApplySearchHighlight applies search highlighting colors to text
 func (c *Config) ApplySearchHighlight(text string, isCurrent bool) string {
 	if isCurrent {
@@ -355,4 +781,4 @@ func (c *Config) ApplySearchHighlight(text string, isCurrent bool) string {
 		fgColor := c.Colors.GetANSIColor(c.Colors.SearchMatch)
 		return fmt.Sprintf("%s%s\033[0m", fgColor, text)
 	}
-}
\ No newline at end of file
+}
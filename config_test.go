@@ -0,0 +1,270 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// mergeLayers merges the config files given as JSON on top of the defaults,
+// returning the paths set and the errors
+func mergeLayers(t *testing.T, layers ...string) (*Config, []string, []error) {
+	t.Helper()
+
+	config := DefaultConfig()
+	var set []string
+	var errs []error
+	for _, layer := range layers {
+		var values map[string]interface{}
+		if err := json.Unmarshal([]byte(layer), &values); err != nil {
+			t.Fatalf("invalid test layer %s: %v", layer, err)
+		}
+		if err := config.merge(values, func(path string) { set = append(set, path) }); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	sort.Strings(set)
+	return config, set, errs
+}
+
+func TestMergeLayers(t *testing.T) {
+	defaults := DefaultConfig()
+
+	tests := []struct {
+		name   string
+		layers []string
+		check  func(c *Config) bool
+		set    []string
+		errors int
+	}{
+		{
+			name:   "empty layer keeps the defaults",
+			layers: []string{`{}`},
+			check:  func(c *Config) bool { return reflect.DeepEqual(c, defaults) },
+		},
+		{
+			name:   "later layer wins",
+			layers: []string{`{"max_width": 80}`, `{"max_width": 100}`},
+			check:  func(c *Config) bool { return c.MaxWidth == 100 },
+			set:    []string{"max_width", "max_width"},
+		},
+		{
+			name:   "unset value keeps the previous layer",
+			layers: []string{`{"max_width": 80, "center": true}`, `{"center": false}`},
+			check:  func(c *Config) bool { return c.MaxWidth == 80 && !c.Center },
+			set:    []string{"center", "center", "max_width"},
+		},
+		{
+			name:   "zero value replaces the previous layer",
+			layers: []string{`{"max_width": 80}`, `{"max_width": 0}`},
+			check:  func(c *Config) bool { return c.MaxWidth == 0 },
+			set:    []string{"max_width", "max_width"},
+		},
+		{
+			name:   "null keeps the previous layer",
+			layers: []string{`{"max_width": 80}`, `{"max_width": null}`},
+			check:  func(c *Config) bool { return c.MaxWidth == 80 },
+			set:    []string{"max_width"},
+		},
+		{
+			name:   "empty list unbinds",
+			layers: []string{`{"keybindings": {"quit": []}}`},
+			check: func(c *Config) bool {
+				return c.Keybindings.Quit != nil && len(c.Keybindings.Quit) == 0
+			},
+			set: []string{"keybindings.quit"},
+		},
+		{
+			name:   "nested objects are merged",
+			layers: []string{`{"colors": {"heading1": "#111111"}}`, `{"colors": {"heading2": "#222222"}}`},
+			check: func(c *Config) bool {
+				return c.Colors.Heading1 == "#111111" && c.Colors.Heading2 == "#222222" &&
+					c.Colors.Heading3 == defaults.Colors.Heading3
+			},
+			set: []string{"colors.heading1", "colors.heading2"},
+		},
+		{
+			name:   "wrong types are skipped",
+			layers: []string{`{"max_width": "wide", "center": true, "colors": 3, "keybindings": {"quit": "q"}}`},
+			check: func(c *Config) bool {
+				return c.MaxWidth == defaults.MaxWidth && c.Center &&
+					reflect.DeepEqual(c.Colors, defaults.Colors) &&
+					reflect.DeepEqual(c.Keybindings.Quit, defaults.Keybindings.Quit)
+			},
+			set:    []string{"center"},
+			errors: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config, set, errs := mergeLayers(t, test.layers...)
+			if !test.check(config) {
+				t.Errorf("unexpected config %+v", config)
+			}
+			if !reflect.DeepEqual(set, test.set) {
+				t.Errorf("set %v, expected %v", set, test.set)
+			}
+			if len(errs) != test.errors {
+				t.Errorf("errors %v, expected %d", errs, test.errors)
+			}
+		})
+	}
+}
+
+func TestMergeTypeErrors(t *testing.T) {
+	_, _, errs := mergeLayers(t, `{"max_width": "wide", "colors": {"heading1": 1}}`)
+	if len(errs) != 1 {
+		t.Fatalf("errors %v, expected 1", errs)
+	}
+
+	typeErrs, ok := errs[0].(configTypeErrors)
+	if !ok {
+		t.Fatalf("unexpected error type %T", errs[0])
+	}
+	var paths []string
+	for _, err := range typeErrs {
+		paths = append(paths, err.path)
+	}
+	expected := []string{"colors.heading1", "max_width"}
+	sort.Strings(paths)
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("paths %v, expected %v", paths, expected)
+	}
+}
+
+func TestApplyTheme(t *testing.T) {
+	dracula, err := themeByName("dracula")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config, set, errs := mergeLayers(t, `{"theme": "dracula", "colors": {"heading1": "#111111"}}`)
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	sources := configSources{}
+	for _, path := range set {
+		sources[path] = "config.json"
+	}
+	if err := config.applyTheme(sources); err != nil {
+		t.Fatal(err)
+	}
+
+	// the color set explicitly is kept, the others come from the theme
+	if config.Colors.Heading1 != "#111111" {
+		t.Errorf("heading1 is %s, expected the explicit #111111", config.Colors.Heading1)
+	}
+	if sources.of("colors.heading1") != "config.json" {
+		t.Errorf("heading1 comes from %s, expected config.json", sources.of("colors.heading1"))
+	}
+	if config.Colors.Heading2 != dracula.colors.Heading2 {
+		t.Errorf("heading2 is %s, expected the theme %s", config.Colors.Heading2, dracula.colors.Heading2)
+	}
+	if sources.of("colors.heading2") != "theme dracula" {
+		t.Errorf("heading2 comes from %s, expected the theme", sources.of("colors.heading2"))
+	}
+	if config.Colors.SearchMatch != dracula.colors.SearchMatch {
+		t.Errorf("search_match is %s, expected the theme %s", config.Colors.SearchMatch, dracula.colors.SearchMatch)
+	}
+
+	tests := []struct {
+		theme string
+		error bool
+	}{
+		{theme: ""},
+		{theme: "Dracula"},
+		{theme: "solarized-dark"},
+		{theme: "bogus", error: true},
+	}
+	for _, test := range tests {
+		config := DefaultConfig()
+		config.Theme = test.theme
+		err := config.applyTheme(configSources{})
+		if test.error != (err != nil) {
+			t.Errorf("theme %q: unexpected error %v", test.theme, err)
+		}
+		if test.theme == "" && !reflect.DeepEqual(config.Colors, DefaultConfig().Colors) {
+			t.Errorf("no theme changed the colors")
+		}
+	}
+}
+
+func TestMissingExplicitConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mdrs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	env := map[string]string{
+		"XDG_CONFIG_DIRS": dir,
+		"MDRS_CONFIG":     filepath.Join(dir, "missing.json"),
+		configOptsEnv:     "--width 30",
+	}
+	for name, value := range env {
+		old, ok := os.LookupEnv(name)
+		os.Setenv(name, value)
+		if ok {
+			defer os.Setenv(name, old)
+		} else {
+			defer os.Unsetenv(name)
+		}
+	}
+
+	// the missing file is reported, the other layers still apply
+	config, sources, err := loadConfigSources(dir)
+	if err == nil || !strings.Contains(err.Error(), "config file not found") {
+		t.Errorf("unexpected error %v", err)
+	}
+	if config.MaxWidth != 30 || sources.of("max_width") != "env "+configOptsEnv {
+		t.Errorf("width %d from %s, expected 30 from %s", config.MaxWidth, sources.of("max_width"), configOptsEnv)
+	}
+}
+
+func TestThemeFiles(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("themes", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != len(themeFiles) {
+		t.Fatalf("%d theme files, %d built in, run go generate", len(paths), len(themeFiles))
+	}
+
+	// the built-in themes are the same as the files
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		name := strings.TrimSuffix(filepath.Base(path), ".json")
+		th, err := themeByName(name)
+		if err != nil {
+			t.Errorf("%s: %v, run go generate", path, err)
+			continue
+		}
+
+		var config struct {
+			Colors ColorConfig `json:"colors"`
+		}
+		if err := json.Unmarshal(data, &config); err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		if config.Colors != th.colors {
+			t.Errorf("%s differs from the built-in theme, run go generate", path)
+		}
+
+		// every color is set
+		colors := reflect.ValueOf(th.colors)
+		for i := 0; i < colors.NumField(); i++ {
+			if colors.Field(i).String() == "" {
+				t.Errorf("%s: %s is not set", path, colors.Type().Field(i).Name)
+			}
+		}
+	}
+}
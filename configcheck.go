@@ -0,0 +1,280 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// configProblem is an issue found in a config file
+type configProblem struct {
+	path   string
+	line   int // 1-based, 0 when unknown
+	column int
+	msg    string
+}
+
+func (p configProblem) String() string {
+	if p.line == 0 {
+		return fmt.Sprintf("%s: %s", p.path, p.msg)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", p.path, p.line, p.column, p.msg)
+}
+
+// CheckConfig validates the config file. A missing config file is not a
+// problem, the defaults are used.
+func CheckConfig(configPath string) ([]configProblem, error) {
+	data, err := ioutil.ReadFile(configPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	problems := checkConfigData(configPath, data)
+	for i := range problems {
+		problems[i].path = configPath
+	}
+	return problems, nil
+}
+
+// checkConfigFiles validates all the config files for the documents of a
+// directory
+func checkConfigFiles(dir string) ([]configProblem, error) {
+	var problems []configProblem
+	for _, path := range getConfigPaths(dir) {
+		fileProblems, err := CheckConfig(path)
+		if err != nil {
+			return nil, err
+		}
+		problems = append(problems, fileProblems...)
+	}
+	return append(problems, checkConfigEnv()...), nil
+}
+
+// checkConfigEnv validates the settings given by the environment, the
+// problems having the variable as path
+func checkConfigEnv() []configProblem {
+	config := DefaultConfig()
+	sources := make(configSources)
+	problems := config.loadEnv(sources)
+
+	if source := sources.of("theme"); strings.HasPrefix(source, "env ") {
+		if _, err := themeByName(config.Theme); err != nil {
+			problems = append(problems, configProblem{path: strings.TrimPrefix(source, "env "), msg: err.Error()})
+		}
+	}
+	return problems
+}
+
+// checkConfigData validates the content of a config file: syntax, unknown
+// fields, value types, colors, keys and duplicate key assignments.
+func checkConfigData(path string, data []byte) []configProblem {
+	var problems []configProblem
+
+	problem := func(pos textPos, format string, args ...interface{}) {
+		problems = append(problems, configProblem{line: pos.line, column: pos.column, msg: fmt.Sprintf(format, args...)})
+	}
+
+	// Syntax errors prevent any further check
+	jsonData, positions, err := decodeConfig(path, data)
+	if err != nil {
+		if serr, ok := err.(*configSyntaxError); ok {
+			problem(serr.pos, "%s", serr.msg)
+		} else {
+			problem(textPos{}, "%v", err)
+		}
+		return problems
+	}
+
+	fieldProblem := func(path string, format string, args ...interface{}) {
+		problem(positions[path], "%s: %s", path, fmt.Sprintf(format, args...))
+	}
+
+	// Unknown fields
+	var raw interface{}
+	_ = json.Unmarshal(jsonData, &raw)
+	findUnknownFields(raw, reflect.TypeOf(Config{}), "", func(path string) {
+		problem(positions[path], "unknown field %q", path)
+	})
+
+	// Values of the wrong type
+	config := DefaultConfig()
+	if object, ok := raw.(map[string]interface{}); !ok {
+		problem(textPos{}, "expected an object, got %s", describeJSON(raw))
+		return problems
+	} else if err := config.merge(object, nil); err != nil {
+		for _, terr := range err.(configTypeErrors) {
+			problem(positions[terr.path], "%v", terr)
+		}
+	}
+
+	// Theme
+	if config.Theme != "" {
+		if _, err := themeByName(config.Theme); err != nil {
+			fieldProblem("theme", "%v", err)
+		}
+	}
+
+	// Colors
+	colors := reflect.ValueOf(config.Colors)
+	for i := 0; i < colors.NumField(); i++ {
+		hex := colors.Field(i).String()
+		if _, err := hexToANSI(hex); err != nil {
+			fieldProblem("colors."+jsonName(colors.Type().Field(i)), "invalid hex color %q", hex)
+		}
+	}
+
+	// Keys
+	bindings, err := (&ui{config: config}).buildKeybindings()
+	if kerrs, ok := err.(keyErrors); ok {
+		for _, kerr := range kerrs {
+			fieldProblem("keybindings."+kerr.action, "invalid key %q: %v", kerr.key, kerr.err)
+		}
+	} else if err != nil {
+		problem(textPos{}, "%v", err)
+	}
+
+	for _, conflict := range findConflicts(bindings, config.Keybindings.isCustom) {
+		// point at an action of the config file, the others are the defaults
+		var pos textPos
+		for _, action := range conflict.actions {
+			if p, found := positions["keybindings."+action]; found {
+				pos = p
+			}
+		}
+		problem(pos, "%v", conflict)
+	}
+
+	sort.SliceStable(problems, func(i, j int) bool {
+		if problems[i].line == 0 || problems[j].line == 0 {
+			return problems[j].line == 0 && problems[i].line != 0
+		}
+		return problems[i].line < problems[j].line
+	})
+
+	return problems
+}
+
+// findUnknownFields reports the keys of a JSON value that match no field of
+// the struct type t
+func findUnknownFields(value interface{}, t reflect.Type, path string, unknown func(path string)) {
+	object, ok := value.(map[string]interface{})
+	if !ok || t.Kind() != reflect.Struct {
+		return
+	}
+
+	// Sorted for a stable report
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		field, found := fieldByJSONName(t, key)
+		if !found {
+			unknown(joinPath(path, key))
+			continue
+		}
+		findUnknownFields(object[key], field.Type, joinPath(path, key), unknown)
+	}
+}
+
+// fieldByJSONName finds the field of a struct encoded with the given JSON name
+func fieldByJSONName(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		if jsonName(t.Field(i)) == name {
+			return t.Field(i), true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// jsonName returns the JSON name of a struct field
+func jsonName(field reflect.StructField) string {
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+// skipSpace returns the offset of the first byte after offset that is neither
+// a space nor a separator
+func skipSpace(data []byte, offset int64) int64 {
+	for offset < int64(len(data)) && strings.IndexByte(" \t\r\n,:", data[offset]) >= 0 {
+		offset++
+	}
+	return offset
+}
+
+// lineColumn converts a byte offset to a 1-based line and column
+func lineColumn(data []byte, offset int64) (int, int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := len(before) - bytes.LastIndexByte(before, '\n')
+	return line, column
+}
+
+// checkConfig prints the problems of the config files for the documents of a
+// directory and exits with an error if there are any
+func checkConfig(w io.Writer, dir string) {
+	configPath := getConfigPath()
+	if _, err := os.Stat(configPath); os.IsNotExist(err) && explicitConfigPath() {
+		exitError(fmt.Errorf("config file not found: %s", configPath))
+	}
+
+	problems, err := checkConfigFiles(dir)
+	if err != nil {
+		exitError(err)
+	}
+
+	if len(problems) == 0 {
+		paths := append(getConfigPaths(dir), configEnvVars()...)
+		if len(paths) == 0 {
+			_, _ = fmt.Fprintln(w, "No config file, using the defaults")
+		}
+		for _, path := range paths {
+			_, _ = fmt.Fprintf(w, "%s: OK\n", path)
+		}
+		return
+	}
+
+	for _, p := range problems {
+		_, _ = fmt.Fprintln(w, p)
+	}
+	os.Exit(1)
+}
+
+// configWarning summarizes the problems of the config files for the documents
+// of a directory on a single line, or returns an empty string if there are none
+func configWarning(dir string) string {
+	problems, err := checkConfigFiles(dir)
+	if err != nil {
+		return fmt.Sprintf("Config: %v", err)
+	}
+	if len(problems) == 0 {
+		return ""
+	}
+
+	// The full path doesn't fit in the status line
+	first := problems[0]
+	first.path = filepath.Base(first.path)
+
+	if len(problems) == 1 {
+		return fmt.Sprintf("Config: %v (see mdrs config check)", first)
+	}
+	return fmt.Sprintf("Config: %v, and %d more problems (see mdrs config check)", first, len(problems)-1)
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// configOptsEnv is the environment variable holding several settings as
+// command line options, like "--width 80 --theme dracula"
+const configOptsEnv = "MDRS_OPTS"
+
+// configOption is a setting that can be set from the environment and the
+// command line
+type configOption struct {
+	// name of the option, like "color_heading1"
+	name string
+	// JSON path of the setting, like "colors.heading1"
+	path string
+	kind reflect.Kind
+	// description of the setting, from its doc tag
+	doc string
+}
+
+// envName returns the environment variable of the option, like
+// MDRS_COLOR_HEADING1
+func (o configOption) envName() string {
+	return "MDRS_" + strings.ToUpper(o.name)
+}
+
+// flagName returns the option as given in MDRS_OPTS, like --color-heading1
+func (o configOption) flagName() string {
+	return "--" + strings.Replace(o.name, "_", "-", -1)
+}
+
+// parse converts the text of an environment variable to the generic JSON
+// form of the setting. Text that doesn't parse is kept as a string, so that
+// merge reports it like a value of the wrong type in a file.
+func (o configOption) parse(text string) interface{} {
+	switch o.kind {
+	case reflect.Bool:
+		if b, err := strconv.ParseBool(text); err == nil {
+			return b
+		}
+	case reflect.Int:
+		if n, err := strconv.Atoi(text); err == nil {
+			return float64(n)
+		}
+	}
+	return text
+}
+
+// configOptions lists the settings that can be set from the environment:
+// the values at the top level by their name, except max_width which is
+// simply width, and the colors prefixed by color_.
+func configOptions() []configOption {
+	var options []configOption
+
+	config := reflect.TypeOf(Config{})
+	for i := 0; i < config.NumField(); i++ {
+		field := config.Field(i)
+		name := jsonName(field)
+		switch {
+		case name == "colors":
+			for j := 0; j < field.Type.NumField(); j++ {
+				color := field.Type.Field(j)
+				options = append(options, configOption{
+					name: "color_" + jsonName(color),
+					path: "colors." + jsonName(color),
+					kind: reflect.String,
+					doc:  color.Tag.Get("doc"),
+				})
+			}
+		case name == "max_width":
+			options = append(options, configOption{name: "width", path: name, kind: field.Type.Kind(), doc: field.Tag.Get("doc")})
+		case field.Type.Kind() != reflect.Struct:
+			options = append(options, configOption{name: name, path: name, kind: field.Type.Kind(), doc: field.Tag.Get("doc")})
+		}
+	}
+
+	return options
+}
+
+// configEnvVars lists the environment variables setting the configuration
+func configEnvVars() []string {
+	var names []string
+	if os.Getenv(configOptsEnv) != "" {
+		names = append(names, configOptsEnv)
+	}
+	for _, option := range configOptions() {
+		if os.Getenv(option.envName()) != "" {
+			names = append(names, option.envName())
+		}
+	}
+	return names
+}
+
+// loadEnv sets the values given by the environment on top of the current
+// configuration, MDRS_OPTS first and then the variables of each setting. The
+// values that can't be used are skipped and returned as problems, with the
+// variable as path.
+func (c *Config) loadEnv(sources configSources) []configProblem {
+	var problems []configProblem
+
+	set := func(name string, option configOption, text string) {
+		err := c.setOption(option, text, func(path string) {
+			if sources != nil {
+				sources[path] = "env " + name
+			}
+		})
+		if err != nil {
+			problems = append(problems, configProblem{path: name, msg: err.Error()})
+		}
+	}
+
+	if opts := os.Getenv(configOptsEnv); opts != "" {
+		values, err := parseConfigOpts(opts)
+		if err != nil {
+			problems = append(problems, configProblem{path: configOptsEnv, msg: err.Error()})
+		}
+		for _, v := range values {
+			set(configOptsEnv, v.option, v.text)
+		}
+	}
+
+	for _, option := range configOptions() {
+		if text := os.Getenv(option.envName()); text != "" {
+			set(option.envName(), option, text)
+		}
+	}
+
+	return problems
+}
+
+// commandLineOptions are the config options given on the command line, which
+// take precedence over the config files and the environment
+var commandLineOptions []configOptValue
+
+// loadCommandLine sets the config options given on the command line, already
+// validated when parsing the flags
+func (c *Config) loadCommandLine(sources configSources) {
+	for _, v := range commandLineOptions {
+		_ = c.setOption(v.option, v.text, func(path string) {
+			sources[path] = "flag " + v.option.flagName()
+		})
+	}
+}
+
+// setOption sets a config option from its text. A value that can't be used
+// is skipped and reported in the returned error. set is called with the path
+// of the value set.
+func (c *Config) setOption(option configOption, text string, set func(path string)) error {
+	if strings.HasPrefix(option.path, "colors.") {
+		if _, err := hexToANSI(text); err != nil {
+			return fmt.Errorf("invalid hex color %q", text)
+		}
+	}
+
+	// Build the nested object of the setting, to merge it like a file
+	values := map[string]interface{}{}
+	object := values
+	keys := strings.Split(option.path, ".")
+	for _, key := range keys[:len(keys)-1] {
+		child := map[string]interface{}{}
+		object[key] = child
+		object = child
+	}
+	object[keys[len(keys)-1]] = option.parse(text)
+
+	err := c.merge(values, set)
+	if terrs, ok := err.(configTypeErrors); ok {
+		return fmt.Errorf("expected %s, got %s", terrs[0].expected, terrs[0].got)
+	}
+	return err
+}
+
+// configOptValue is an option of MDRS_OPTS and its value
+type configOptValue struct {
+	option configOption
+	text   string
+}
+
+// parseConfigOpts parses the options of MDRS_OPTS. Each option takes a value,
+// as "--width 80" or "--width=80", except the boolean ones which are set by
+// "--center" and cleared by "--no-center". The values can be quoted like in
+// a shell.
+func parseConfigOpts(opts string) ([]configOptValue, error) {
+	words, err := splitWords(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []configOptValue
+	for i := 0; i < len(words); i++ {
+		word := words[i]
+		name, text, hasValue := word, "", false
+		if eq := strings.IndexByte(word, '='); eq >= 0 {
+			name, text, hasValue = word[:eq], word[eq+1:], true
+		}
+
+		option, negated, found := findConfigOption(name)
+		if !found {
+			return result, fmt.Errorf("unknown option %q", word)
+		}
+
+		switch {
+		case option.kind == reflect.Bool && !hasValue:
+			text = strconv.FormatBool(!negated)
+		case negated:
+			return result, fmt.Errorf("option %s takes no value", name)
+		case !hasValue:
+			if i+1 == len(words) {
+				return result, fmt.Errorf("option %s needs a value", name)
+			}
+			i++
+			text = words[i]
+		}
+
+		result = append(result, configOptValue{option: option, text: text})
+	}
+	return result, nil
+}
+
+// findConfigOption finds an option by its flag name, also telling if it is
+// the negated form of a boolean option, like --no-center
+func findConfigOption(flag string) (configOption, bool, bool) {
+	for _, option := range configOptions() {
+		if option.flagName() == flag {
+			return option, false, true
+		}
+		if option.kind == reflect.Bool && "--no-"+strings.TrimPrefix(option.flagName(), "--") == flag {
+			return option, true, true
+		}
+	}
+	return configOption{}, false, false
+}
+
+// splitWords splits a string into words like a shell, with single quotes,
+// double quotes and backslash escapes
+func splitWords(s string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	var quote rune
+
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != '\'' && r == '\\' && i+1 < len(runes):
+			i++
+			word.WriteRune(runes[i])
+			inWord = true
+		case quote != 0:
+			word.WriteRune(r)
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
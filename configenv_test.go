@@ -0,0 +1,90 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitWords(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+		error    bool
+	}{
+		{input: "", expected: nil},
+		{input: "  ", expected: nil},
+		{input: "--width 80", expected: []string{"--width", "80"}},
+		{input: " --center\t--width\n80 ", expected: []string{"--center", "--width", "80"}},
+		{input: `--status-format '{file}  {line}'`, expected: []string{"--status-format", "{file}  {line}"}},
+		{input: `--status-format "{file}  {line}"`, expected: []string{"--status-format", "{file}  {line}"}},
+		{input: `--status-format={file}' '{line}`, expected: []string{"--status-format={file} {line}"}},
+		{input: `a\ b c`, expected: []string{"a b", "c"}},
+		{input: `"a \"b\""`, expected: []string{`a "b"`}},
+		{input: `'a \b'`, expected: []string{`a \b`}},
+		{input: `''`, expected: []string{""}},
+		{input: `'unterminated`, error: true},
+		{input: `"unterminated`, error: true},
+	}
+
+	for _, test := range tests {
+		words, err := splitWords(test.input)
+		switch {
+		case test.error && err == nil:
+			t.Errorf("%q: expected an error, got %q", test.input, words)
+		case !test.error && err != nil:
+			t.Errorf("%q: unexpected error: %v", test.input, err)
+		case !test.error && !reflect.DeepEqual(words, test.expected):
+			t.Errorf("%q: got %q, expected %q", test.input, words, test.expected)
+		}
+	}
+}
+
+func TestParseConfigOpts(t *testing.T) {
+	tests := []struct {
+		opts string
+		// JSON path and text of each option
+		expected [][2]string
+		error    bool
+	}{
+		{opts: "", expected: nil},
+		{opts: "--width 80", expected: [][2]string{{"max_width", "80"}}},
+		{opts: "--width=80", expected: [][2]string{{"max_width", "80"}}},
+		{opts: "--center", expected: [][2]string{{"center", "true"}}},
+		{opts: "--no-center", expected: [][2]string{{"center", "false"}}},
+		{opts: "--center=false", expected: [][2]string{{"center", "false"}}},
+		{
+			opts:     "--theme dracula --color-heading1 '#ff5555'",
+			expected: [][2]string{{"theme", "dracula"}, {"colors.heading1", "#ff5555"}},
+		},
+		{opts: `--status-format "{file} {line}"`, expected: [][2]string{{"status_format", "{file} {line}"}}},
+		{opts: "--bogus", error: true},
+		{opts: "--width 80 --bogus 1", error: true},
+		{opts: "width 80", error: true},
+		{opts: "--no-width", error: true},
+		{opts: "--no-center=true", error: true},
+		{opts: "--width", error: true},
+		{opts: "--theme 'dracula", error: true},
+	}
+
+	for _, test := range tests {
+		values, err := parseConfigOpts(test.opts)
+		if test.error {
+			if err == nil {
+				t.Errorf("%q: expected an error", test.opts)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", test.opts, err)
+			continue
+		}
+
+		var got [][2]string
+		for _, value := range values {
+			got = append(got, [2]string{value.option.path, value.text})
+		}
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%q: got %v, expected %v", test.opts, got, test.expected)
+		}
+	}
+}
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// textPos is a 1-based position in a config file
+type textPos struct {
+	line   int
+	column int
+}
+
+// configSyntaxError is a config file that can't be parsed
+type configSyntaxError struct {
+	pos textPos // zero when unknown
+	msg string
+}
+
+func (e *configSyntaxError) Error() string {
+	if e.pos.line == 0 {
+		return e.msg
+	}
+	return fmt.Sprintf("line %d, column %d: %s", e.pos.line, e.pos.column, e.msg)
+}
+
+// configFormat is a format of config file. Config files are decoded to JSON,
+// so that all the formats share the JSON names and decoding of the Config.
+type configFormat struct {
+	name       string
+	extensions []string
+
+	// decode converts a config file to JSON, giving the position of each
+	// object key by path, like "colors.heading1", when known
+	decode func(data []byte) ([]byte, map[string]textPos, error)
+
+	// template writes the default config with every setting commented out,
+	// nil if the format has no comments
+	template func(config *Config) string
+}
+
+var configFormats = []*configFormat{
+	{name: "json", extensions: []string{".json"}, decode: decodeJSONConfig},
+	{name: "yaml", extensions: []string{".yaml", ".yml"}, decode: decodeYAMLConfig, template: yamlConfigTemplate},
+	{name: "toml", extensions: []string{".toml"}, decode: decodeTOMLConfig, template: tomlConfigTemplate},
+}
+
+// configFormatByName returns the config format with the given name
+func configFormatByName(name string) (*configFormat, error) {
+	for _, format := range configFormats {
+		if format.name == strings.ToLower(name) {
+			return format, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown config format %q, expected json, yaml or toml", name)
+}
+
+// configFormatOf returns the format of a config file from its extension, JSON
+// when unknown
+func configFormatOf(path string) *configFormat {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, format := range configFormats {
+		for _, e := range format.extensions {
+			if e == ext {
+				return format
+			}
+		}
+	}
+	return configFormats[0]
+}
+
+// configFileNames returns the possible names of a config file, by order of
+// preference
+func configFileNames(base string) []string {
+	var names []string
+	for _, format := range configFormats {
+		for _, ext := range format.extensions {
+			names = append(names, base+ext)
+		}
+	}
+	return names
+}
+
+// decodeConfig converts a config file of any format to JSON
+func decodeConfig(path string, data []byte) ([]byte, map[string]textPos, error) {
+	return configFormatOf(path).decode(data)
+}
+
+func decodeJSONConfig(data []byte) ([]byte, map[string]textPos, error) {
+	var raw interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		if serr, ok := err.(*json.SyntaxError); ok {
+			// the offset is after the offending character
+			return nil, nil, &configSyntaxError{pos: offsetPos(data, serr.Offset-1), msg: serr.Error()}
+		}
+		return nil, nil, &configSyntaxError{msg: err.Error()}
+	}
+
+	positions := make(map[string]textPos)
+	walkJSONKeys(json.NewDecoder(bytes.NewReader(data)), data, "", positions)
+
+	return data, positions, nil
+}
+
+// walkJSONKeys reads the next JSON value of the decoder, recording the
+// position of each object key
+func walkJSONKeys(dec *json.Decoder, data []byte, path string, positions map[string]textPos) {
+	tok, err := dec.Token()
+	if err != nil {
+		return
+	}
+
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		return
+	}
+
+	if delim == '[' {
+		for dec.More() {
+			walkJSONKeys(dec, data, path, positions)
+		}
+		_, _ = dec.Token()
+		return
+	}
+
+	for dec.More() {
+		offset := skipSpace(data, dec.InputOffset())
+		tok, err := dec.Token()
+		if err != nil {
+			return
+		}
+		key, _ := tok.(string)
+
+		keyPath := joinPath(path, key)
+		positions[keyPath] = offsetPos(data, offset)
+
+		walkJSONKeys(dec, data, keyPath, positions)
+	}
+	_, _ = dec.Token()
+}
+
+// yamlErrorLine extracts the line from the YAML parser errors
+var yamlErrorLine = regexp.MustCompile(`^yaml: line (\d+): (.*)$`)
+
+func decodeYAMLConfig(data []byte) ([]byte, map[string]textPos, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		if m := yamlErrorLine.FindStringSubmatch(err.Error()); m != nil {
+			line, _ := strconv.Atoi(m[1])
+			return nil, nil, &configSyntaxError{pos: textPos{line: line, column: 1}, msg: m[2]}
+		}
+		return nil, nil, &configSyntaxError{msg: err.Error()}
+	}
+
+	// An empty document sets nothing
+	var value interface{} = map[string]interface{}{}
+	positions := make(map[string]textPos)
+	if len(doc.Content) > 0 {
+		var err error
+		value, err = yamlValue(doc.Content[0], "", positions)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	result, err := json.Marshal(value)
+	if err != nil {
+		return nil, nil, err
+	}
+	return result, positions, nil
+}
+
+// yamlValue converts a YAML node to its generic JSON form, recording the
+// position of each mapping key
+func yamlValue(node *yaml.Node, path string, positions map[string]textPos) (interface{}, error) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		result := make(map[string]interface{})
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			keyPath := joinPath(path, key.Value)
+			positions[keyPath] = textPos{line: key.Line, column: key.Column}
+
+			v, err := yamlValue(value, keyPath, positions)
+			if err != nil {
+				return nil, err
+			}
+			result[key.Value] = v
+		}
+		return result, nil
+
+	case yaml.SequenceNode:
+		result := make([]interface{}, 0, len(node.Content))
+		for _, item := range node.Content {
+			v, err := yamlValue(item, path, positions)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, v)
+		}
+		return result, nil
+
+	case yaml.AliasNode:
+		return yamlValue(node.Alias, path, positions)
+
+	default:
+		var result interface{}
+		if err := node.Decode(&result); err != nil {
+			return nil, &configSyntaxError{pos: textPos{line: node.Line, column: node.Column}, msg: err.Error()}
+		}
+		return result, nil
+	}
+}
+
+// tomlErrorPrefix is the location at the start of the TOML parser errors
+var tomlErrorPrefix = regexp.MustCompile(`^toml: line \d+( \(last key "[^"]*"\))?: `)
+
+func decodeTOMLConfig(data []byte) ([]byte, map[string]textPos, error) {
+	var value map[string]interface{}
+	if _, err := toml.Decode(string(data), &value); err != nil {
+		if perr, ok := err.(toml.ParseError); ok {
+			pos := offsetPos(data, int64(perr.Position.Start))
+			if pos.line != perr.Position.Line {
+				// the error is at the end of the previous line
+				pos = textPos{line: perr.Position.Line, column: 1}
+			}
+			msg := tomlErrorPrefix.ReplaceAllString(perr.Error(), "")
+			return nil, nil, &configSyntaxError{pos: pos, msg: msg}
+		}
+		return nil, nil, &configSyntaxError{msg: err.Error()}
+	}
+
+	// The TOML parser doesn't give the position of the keys
+	result, err := json.Marshal(value)
+	if err != nil {
+		return nil, nil, err
+	}
+	return result, nil, nil
+}
+
+// yamlConfigTemplate writes the config as YAML with every setting commented
+// out, the settings keeping their default value until uncommented
+func yamlConfigTemplate(config *Config) string {
+	var sb strings.Builder
+	sb.WriteString("# mdrs configuration\n")
+	sb.WriteString("#\n")
+	sb.WriteString("# Every setting is commented out and has its default value. Uncomment the\n")
+	sb.WriteString("# ones to change, the others keep the defaults of future versions.\n")
+
+	var write func(v reflect.Value, indent string)
+	write = func(v reflect.Value, indent string) {
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+
+			if v.Field(i).Kind() == reflect.Struct {
+				sb.WriteString("\n")
+				writeDoc(&sb, indent, field)
+				sb.WriteString(fmt.Sprintf("%s%s:\n", indent, jsonName(field)))
+				write(v.Field(i), indent+"  ")
+				continue
+			}
+
+			writeDoc(&sb, indent, field)
+			sb.WriteString(fmt.Sprintf("%s# %s: %s\n", indent, jsonName(field), jsonValue(v.Field(i))))
+		}
+	}
+	write(reflect.ValueOf(*config), "")
+
+	return sb.String()
+}
+
+// tomlConfigTemplate writes the config as TOML with every setting commented
+// out, the settings keeping their default value until uncommented
+func tomlConfigTemplate(config *Config) string {
+	var sb strings.Builder
+	sb.WriteString("# mdrs configuration\n")
+	sb.WriteString("#\n")
+	sb.WriteString("# Every setting is commented out and has its default value. Uncomment the\n")
+	sb.WriteString("# ones to change, the others keep the defaults of future versions.\n")
+
+	// TOML needs the values of a table before its sub-tables
+	var write func(v reflect.Value, table string)
+	write = func(v reflect.Value, table string) {
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if v.Field(i).Kind() != reflect.Struct {
+				writeDoc(&sb, "", field)
+				sb.WriteString(fmt.Sprintf("# %s = %s\n", jsonName(field), jsonValue(v.Field(i))))
+			}
+		}
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if v.Field(i).Kind() == reflect.Struct {
+				name := joinPath(table, jsonName(field))
+				sb.WriteString("\n")
+				writeDoc(&sb, "", field)
+				sb.WriteString(fmt.Sprintf("[%s]\n", name))
+				write(v.Field(i), name)
+			}
+		}
+	}
+	sb.WriteString("\n")
+	write(reflect.ValueOf(*config), "")
+
+	return sb.String()
+}
+
+// writeDoc writes the documentation of a config field as a comment
+func writeDoc(sb *strings.Builder, indent string, field reflect.StructField) {
+	if doc := field.Tag.Get("doc"); doc != "" {
+		sb.WriteString(fmt.Sprintf("%s## %s\n", indent, doc))
+	}
+}
+
+// jsonValue encodes a value in JSON, which is also valid in YAML and TOML for
+// the values of the config
+func jsonValue(v reflect.Value) string {
+	data, err := json.Marshal(v.Interface())
+	if err != nil {
+		return fmt.Sprint(v.Interface())
+	}
+	return string(data)
+}
+
+// offsetPos converts a byte offset to a position
+func offsetPos(data []byte, offset int64) textPos {
+	line, column := lineColumn(data, offset)
+	return textPos{line: line, column: column}
+}
+
+// joinPath appends a key to the path of its parent
+func joinPath(path string, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
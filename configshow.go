@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+)
+
+// configValue is a value of the configuration and its JSON path
+type configValue struct {
+	path  string
+	value string
+}
+
+// flattenConfig lists the values of a config struct in JSON form, in the
+// order of the fields
+func flattenConfig(v reflect.Value, prefix string) []configValue {
+	var result []configValue
+	for i := 0; i < v.NumField(); i++ {
+		path := jsonName(v.Type().Field(i))
+		if prefix != "" {
+			path = prefix + "." + path
+		}
+
+		field := v.Field(i)
+		if field.Kind() == reflect.Struct {
+			result = append(result, flattenConfig(field, path)...)
+			continue
+		}
+
+		data, err := json.Marshal(field.Interface())
+		if err != nil {
+			data = []byte(fmt.Sprint(field.Interface()))
+		}
+		result = append(result, configValue{path: path, value: string(data)})
+	}
+	return result
+}
+
+// showConfig prints the effective configuration for the documents of a
+// directory, along with where each value comes from
+func showConfig(w io.Writer, dir string) {
+	// The values that can't be loaded are skipped, like when viewing
+	config, sources, err := loadConfigSources(dir)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+	}
+
+	paths := getConfigPaths(dir)
+	if len(paths) == 0 {
+		_, _ = fmt.Fprintln(w, "# No config file, using the defaults")
+	} else {
+		_, _ = fmt.Fprintln(w, "# Config files, from the least to the most important:")
+		for _, path := range paths {
+			_, _ = fmt.Fprintf(w, "#   %s\n", path)
+		}
+	}
+	if vars := configEnvVars(); len(vars) > 0 {
+		_, _ = fmt.Fprintf(w, "# Environment, applied after the files: %s\n", strings.Join(vars, ", "))
+	}
+	_, _ = fmt.Fprintln(w)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, v := range flattenConfig(reflect.ValueOf(*config), "") {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t# %s\n", v.path, v.value, sources.of(v.path))
+	}
+	_ = tw.Flush()
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/awesome-gocui/gocui"
+)
+
+// configPollInterval is how often the config files are checked for changes
+const configPollInterval = time.Second
+
+// configStamp identifies the state of the config files for the documents of a
+// directory, it changes when a config file is created, edited or removed
+func configStamp(dir string) string {
+	var sb strings.Builder
+	for _, path := range getConfigPaths(dir) {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("%s %d %d\n", path, info.Size(), info.ModTime().UnixNano()))
+	}
+	return sb.String()
+}
+
+// watchConfig reloads the config whenever one of its files changes, so that
+// the changes are visible without restarting
+func (ui *ui) watchConfig(g *gocui.Gui) {
+	// The current directory is the one of the file, if any
+	stamp := configStamp(".")
+
+	go func() {
+		for range time.Tick(configPollInterval) {
+			current := configStamp(".")
+			if current == stamp {
+				continue
+			}
+			stamp = current
+			g.Update(ui.reloadConfig)
+		}
+	}()
+}
+
+// reloadConfig loads the config again and applies it. The problems of the
+// config are reported in the status line, the settings that could be loaded
+// still apply.
+func (ui *ui) reloadConfig(g *gocui.Gui) error {
+	config, err := LoadConfig(".")
+	previous := ui.config
+
+	ui.config = config
+	ui.search.config = config
+	ui.help.config = config
+
+	// Keep the width and status line changed while reading, unless the
+	// config changes them too
+	if config.MaxWidth != previous.MaxWidth {
+		ui.maxWidth = config.MaxWidth
+	}
+	if config.HideStatus != previous.HideStatus {
+		ui.showStatus = !config.HideStatus
+	}
+
+	// The keys that can't be parsed are reported below with the others
+	keybindings, _ := ui.buildKeybindings()
+	if kerr := ui.setKeybindings(g, keybindings); kerr != nil && err == nil {
+		err = kerr
+	}
+
+	// Render again with the new colors and options
+	ui.width = -1
+
+	warning := configWarning(".")
+	if warning == "" && err != nil {
+		warning = fmt.Sprintf("Config: %v", err)
+	}
+	if warning != "" {
+		ui.showMessage(g, warning, warningDuration)
+		return nil
+	}
+	ui.setMessage(g, "Config reloaded")
+	return nil
+}
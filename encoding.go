@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// textEncoding is a character encoding of the documents, converted to UTF-8
+// before rendering
+type textEncoding struct {
+	name    string
+	aliases []string
+	bom     []byte
+	decode  func(data []byte) string
+}
+
+var textEncodings = []*textEncoding{
+	{
+		name:    "utf-8",
+		aliases: []string{"utf8"},
+		bom:     []byte{0xef, 0xbb, 0xbf},
+		decode:  decodeUTF8,
+	},
+	{
+		name:    "utf-16le",
+		aliases: []string{"utf16le"},
+		bom:     []byte{0xff, 0xfe},
+		decode:  func(data []byte) string { return decodeUTF16(data, false) },
+	},
+	{
+		name:    "utf-16be",
+		aliases: []string{"utf16be"},
+		bom:     []byte{0xfe, 0xff},
+		decode:  func(data []byte) string { return decodeUTF16(data, true) },
+	},
+	{
+		name:    "windows-1252",
+		aliases: []string{"cp1252"},
+		decode:  func(data []byte) string { return decode8bit(data, windows1252) },
+	},
+	{
+		name:    "iso-8859-1",
+		aliases: []string{"latin1", "latin-1"},
+		decode:  func(data []byte) string { return decode8bit(data, nil) },
+	},
+	{
+		name:    "iso-8859-15",
+		aliases: []string{"latin9", "latin-9"},
+		decode:  func(data []byte) string { return decode8bit(data, iso885915) },
+	},
+}
+
+// autoEncoding detects the encoding of the documents
+const autoEncoding = "auto"
+
+// encodingByName returns the encoding with the given name or alias
+func encodingByName(name string) (*textEncoding, error) {
+	name = strings.ToLower(name)
+	for _, e := range textEncodings {
+		if e.name == name || containsString(e.aliases, name) {
+			return e, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown encoding %q, expected %s or %s", name, autoEncoding, strings.Join(encodingNames(), ", "))
+}
+
+// encodingNames lists the names of the encodings
+func encodingNames() []string {
+	names := make([]string, len(textEncodings))
+	for i, e := range textEncodings {
+		names[i] = e.name
+	}
+	return names
+}
+
+// detectEncoding guesses the encoding of a document:
+//   - a byte order mark gives the encoding, UTF-8 or UTF-16
+//   - text made of ASCII characters and zero bytes is UTF-16 without BOM
+//   - text mostly made of valid UTF-8 is UTF-8, a few stray bytes not
+//     spoiling the whole document
+//   - anything else is taken as Windows-1252, the superset of Latin-1 most
+//     legacy documents are written in
+func detectEncoding(data []byte) *textEncoding {
+	for _, e := range textEncodings {
+		if e.bom != nil && bytes.HasPrefix(data, e.bom) {
+			return e
+		}
+	}
+
+	if e := detectUTF16(data); e != nil {
+		return e
+	}
+
+	if !mostlyUTF8(data) {
+		e, _ := encodingByName("windows-1252")
+		return e
+	}
+	return textEncodings[0]
+}
+
+// mostlyUTF8 tells whether the invalid UTF-8 sequences of the text are not
+// more than its valid multibyte characters. Legacy 8-bit text has almost only
+// invalid sequences, while UTF-8 text may have a few stray bytes. A character
+// cut at the end, like in a truncated file, is not counted as invalid.
+func mostlyUTF8(data []byte) bool {
+	var valid, invalid int
+	for i := 0; i < len(data); {
+		if data[i] < utf8.RuneSelf {
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRune(data[i:])
+		switch {
+		case r != utf8.RuneError || size > 1:
+			valid++
+		case !utf8.FullRune(data[i:]):
+			// cut at the end
+		default:
+			invalid++
+		}
+		i += size
+	}
+	return invalid <= valid
+}
+
+// detectUTF16 recognizes UTF-16 text without BOM by the zero bytes of its
+// ASCII characters, all on the same side
+func detectUTF16(data []byte) *textEncoding {
+	sample := data
+	if len(sample) > 4096 {
+		sample = sample[:4096]
+	}
+	if len(sample) < 2 {
+		return nil
+	}
+
+	var zeros [2]int
+	for i, b := range sample {
+		if b == 0 {
+			zeros[i%2]++
+		}
+	}
+
+	pairs := len(sample) / 2
+	switch {
+	case zeros[1] > pairs/2 && zeros[0] == 0:
+		e, _ := encodingByName("utf-16le")
+		return e
+	case zeros[0] > pairs/2 && zeros[1] == 0:
+		e, _ := encodingByName("utf-16be")
+		return e
+	}
+	return nil
+}
+
+// decodeText converts a document to UTF-8, from the given encoding or from
+// the detected one with autoEncoding. The byte order mark is removed.
+func decodeText(data []byte, encoding string) (string, error) {
+	var e *textEncoding
+	if encoding == "" || encoding == autoEncoding {
+		e = detectEncoding(data)
+	} else {
+		var err error
+		e, err = encodingByName(encoding)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return e.decodeText(data), nil
+}
+
+// decodeText converts text in this encoding to UTF-8, without its byte order
+// mark
+func (e *textEncoding) decodeText(data []byte) string {
+	if e.bom != nil {
+		data = bytes.TrimPrefix(data, e.bom)
+	}
+	return e.decode(data)
+}
+
+// decodeUTF8 returns the text with its invalid bytes replaced by U+FFFD
+func decodeUTF8(data []byte) string {
+	if utf8.Valid(data) {
+		return string(data)
+	}
+
+	var sb strings.Builder
+	sb.Grow(len(data))
+	for len(data) > 0 {
+		r, size := utf8.DecodeRune(data)
+		sb.WriteRune(r)
+		data = data[size:]
+	}
+	return sb.String()
+}
+
+func decodeUTF16(data []byte, bigEndian bool) string {
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		if bigEndian {
+			units[i] = uint16(data[2*i])<<8 | uint16(data[2*i+1])
+		} else {
+			units[i] = uint16(data[2*i+1])<<8 | uint16(data[2*i])
+		}
+	}
+	return string(utf16.Decode(units))
+}
+
+// decode8bit converts text in an 8-bit encoding, the characters from 0x80
+// being Latin-1 except the ones given by the table
+func decode8bit(data []byte, table map[byte]rune) string {
+	var sb strings.Builder
+	sb.Grow(len(data))
+	for _, b := range data {
+		if r, ok := table[b]; ok {
+			sb.WriteRune(r)
+		} else {
+			sb.WriteRune(rune(b))
+		}
+	}
+	return sb.String()
+}
+
+// windows1252 are the characters of Windows-1252 differing from Latin-1
+var windows1252 = map[byte]rune{
+	0x80: '€', 0x82: '‚', 0x83: 'ƒ', 0x84: '„', 0x85: '…', 0x86: '†', 0x87: '‡',
+	0x88: 'ˆ', 0x89: '‰', 0x8a: 'Š', 0x8b: '‹', 0x8c: 'Œ', 0x8e: 'Ž',
+	0x91: '‘', 0x92: '’', 0x93: '“', 0x94: '”', 0x95: '•', 0x96: '–', 0x97: '—',
+	0x98: '˜', 0x99: '™', 0x9a: 'š', 0x9b: '›', 0x9c: 'œ', 0x9e: 'ž', 0x9f: 'Ÿ',
+}
+
+// iso885915 are the characters of ISO-8859-15 differing from Latin-1
+var iso885915 = map[byte]rune{
+	0xa4: '€', 0xa6: 'Š', 0xa8: 'š', 0xb4: 'Ž', 0xb8: 'ž', 0xbc: 'Œ', 0xbd: 'œ', 0xbe: 'Ÿ',
+}
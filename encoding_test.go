@@ -0,0 +1,91 @@
+package main
+
+import (
+	"testing"
+	"unicode/utf16"
+)
+
+// utf16Bytes encodes text in UTF-16, with the given byte order mark
+func utf16Bytes(text string, bigEndian bool, bom bool) []byte {
+	units := utf16.Encode([]rune(text))
+	if bom {
+		units = append([]uint16{0xfeff}, units...)
+	}
+	data := make([]byte, 0, 2*len(units))
+	for _, u := range units {
+		if bigEndian {
+			data = append(data, byte(u>>8), byte(u))
+		} else {
+			data = append(data, byte(u), byte(u>>8))
+		}
+	}
+	return data
+}
+
+func TestDetectEncoding(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		expected string
+	}{
+		{"empty", nil, "utf-8"},
+		{"ascii", []byte("# Title\n"), "utf-8"},
+		{"utf-8", []byte("# Café\n"), "utf-8"},
+		{"utf-8 bom", append([]byte{0xef, 0xbb, 0xbf}, "# Title"...), "utf-8"},
+		{"utf-16le bom", utf16Bytes("# Café", false, true), "utf-16le"},
+		{"utf-16be bom", utf16Bytes("# Café", true, true), "utf-16be"},
+		{"utf-16le without bom", utf16Bytes("# Title\n", false, false), "utf-16le"},
+		{"utf-16be without bom", utf16Bytes("# Title\n", true, false), "utf-16be"},
+		// a few zero bytes in 8-bit text are not enough for UTF-16
+		{"zero bytes in ascii", []byte("# Title\x00 and more text\n"), "utf-8"},
+		{"latin-1", []byte("# Caf\xe9\n"), "windows-1252"},
+		{"windows-1252 quotes", []byte("\x93quoted\x94\n"), "windows-1252"},
+		{"truncated utf-8", []byte("# Caf\xc3"), "utf-8"},
+		{"utf-8 with a stray byte", []byte("# Café\x93\n"), "utf-8"},
+		{"utf-8 with stray bytes", []byte("# Café déjà vu\xff\xfe\n"), "utf-8"},
+		{"mostly windows-1252", []byte("# Café \x93caf\xe9\x94\n"), "windows-1252"},
+	}
+
+	for _, test := range tests {
+		if got := detectEncoding(test.data); got.name != test.expected {
+			t.Errorf("%s: got %s, expected %s", test.name, got.name, test.expected)
+		}
+	}
+}
+
+func TestDecodeText(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		encoding string
+		expected string
+		error    bool
+	}{
+		{name: "utf-8", data: []byte("# Café"), expected: "# Café"},
+		{name: "utf-8 bom removed", data: []byte("\xef\xbb\xbf# Café"), expected: "# Café"},
+		{name: "utf-16le bom removed", data: utf16Bytes("# Café", false, true), expected: "# Café"},
+		{name: "utf-16be bom removed", data: utf16Bytes("# Café", true, true), expected: "# Café"},
+		{name: "utf-16le without bom", data: utf16Bytes("# Title", false, false), expected: "# Title"},
+		{name: "utf-16 surrogate pair", data: utf16Bytes("# 😀", false, true), expected: "# 😀"},
+		{name: "windows-1252 fallback", data: []byte("\x93Caf\xe9\x94 \x80"), expected: "“Café” €"},
+		{name: "auto", data: []byte("Caf\xe9\n"), encoding: autoEncoding, expected: "Café\n"},
+		{name: "latin-1", data: []byte("Caf\xe9 \x80"), encoding: "latin1", expected: "Café \u0080"},
+		{name: "iso-8859-15", data: []byte("\xa4"), encoding: "iso-8859-15", expected: "€"},
+		{name: "forced utf-16be", data: []byte{0x00, 'a'}, encoding: "UTF-16BE", expected: "a"},
+		{name: "utf-8 with a stray byte", data: []byte("Café \x93"), expected: "Café \ufffd"},
+		{name: "forced utf-8 replaces invalid bytes", data: []byte("Caf\xe9"), encoding: "utf8", expected: "Caf\ufffd"},
+		{name: "unknown encoding", data: []byte("a"), encoding: "ebcdic", error: true},
+	}
+
+	for _, test := range tests {
+		got, err := decodeText(test.data, test.encoding)
+		switch {
+		case test.error && err == nil:
+			t.Errorf("%s: expected an error, got %q", test.name, got)
+		case !test.error && err != nil:
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+		case !test.error && got != test.expected:
+			t.Errorf("%s: got %q, expected %q", test.name, got, test.expected)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+
+	"github.com/MichaelMure/go-term-markdown"
+)
+
+// defaultExportWidth is the width of the exported text when neither the
+// config nor $COLUMNS give one
+const defaultExportWidth = 80
+
+// ansiEscape matches the escape sequences styling the rendered text
+var ansiEscape = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+// exportFile renders the markdown file given as argument, or stdin, to w. The
+// text is max_width wide, or as wide as $COLUMNS.
+func exportFile(w io.Writer, files []string, encoding string, plain bool) error {
+	content, _, err := readDocument(files, encoding)
+	if err != nil {
+		return err
+	}
+
+	// A broken config is reported like in the viewer, with the defaults
+	// applying instead of the broken values
+	dir := configDir(files)
+	config, err := LoadConfig(dir)
+	warning := configWarning(dir)
+	if warning == "" && err != nil {
+		warning = fmt.Sprintf("Config: %v", err)
+	}
+	if warning != "" {
+		_, _ = fmt.Fprintln(os.Stderr, warning)
+	}
+
+	width := config.MaxWidth
+	if width <= 0 {
+		width = defaultExportWidth
+		if columns, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && columns > padding {
+			width = columns - 1 - padding
+		}
+	}
+
+	rendered := markdown.Render(string(content), width+padding, padding, config.GetMarkdownOptions()...)
+	if plain {
+		rendered = ansiEscape.ReplaceAll(rendered, nil)
+	}
+
+	_, err = w.Write(rendered)
+	return err
+}
@@ -28,8 +28,10 @@
             
             # Generate vendor hash with: nix run nixpkgs#nix-prefetch-git -- --url . --fetch-submodules
             # Or let nix tell you the correct hash on first build
-            vendorHash = "sha256-rCd3Zccr2B1kGXnRqUDuBYpe78PP9LiDuAMMr0jvkvI=";
+            vendorHash = "sha256-EVKfSRN9FpEDZLgSKLzqzXwDHZOxVYZSS4+J4vwkOP8=";
             
+            nativeBuildInputs = [ pkgs.installShellFiles ];
+
             # Add version information as build flags
             ldflags = [
               "-s"
@@ -45,6 +47,14 @@
               if [ -f $out/bin/mdr ] && [ ! -f $out/bin/mdrs ]; then
                 mv $out/bin/mdr $out/bin/mdrs
               fi
+
+              installShellCompletion --cmd mdrs \
+                --bash <($out/bin/mdrs completion bash) \
+                --zsh <($out/bin/mdrs completion zsh) \
+                --fish <($out/bin/mdrs completion fish)
+
+              $out/bin/mdrs man > mdrs.1
+              installManPage mdrs.1
             '';
             
             # Disable tests that might require network access
@@ -3,11 +3,17 @@ module github.com/MichaelMure/mdrs
 go 1.12
 
 require (
+	github.com/BurntSushi/toml v1.3.2
 	github.com/MichaelMure/go-term-markdown v0.1.3
+	github.com/MichaelMure/go-term-text v0.2.7
 	github.com/alecthomas/repr v0.0.0-20181024024818-d37bc2a10ba1 // indirect
 	github.com/awesome-gocui/gocui v0.6.0
 	github.com/dlclark/regexp2 v1.1.8 // indirect
+	github.com/fatih/color v1.9.0
+	github.com/gomarkdown/markdown v0.0.0-20191123064959-2c17d62f5098
 	github.com/mattn/go-isatty v0.0.12
 	github.com/mattn/go-runewidth v0.0.9
 	github.com/pkg/errors v0.9.1
+	github.com/ulikunitz/xz v0.5.12
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	text "github.com/MichaelMure/go-term-text"
+	md "github.com/gomarkdown/markdown"
+	"github.com/gomarkdown/markdown/ast"
+	"github.com/gomarkdown/markdown/parser"
+)
+
+// heading is a section title of the document
+type heading struct {
+	level int
+	// numbering as displayed by the renderer, like "1.2.1"
+	number string
+	title  string
+	// line of the heading in the rendered document, -1 if not found
+	line int
+}
+
+// parseHeadings extracts the headings of a markdown document, numbered the
+// same way the renderer does.
+func parseHeadings(raw string) []heading {
+	// Same extensions as the renderer, so that both see the same headings
+	extensions := parser.NoIntraEmphasis | parser.Tables | parser.FencedCode |
+		parser.Autolink | parser.Strikethrough | parser.SpaceHeadings |
+		parser.HeadingIDs | parser.BackslashLineBreak | parser.DefinitionLists |
+		parser.LaxHTMLBlocks | parser.NoEmptyLineBeforeBlock
+
+	doc := md.Parse([]byte(raw), parser.NewWithExtensions(extensions))
+
+	var result []heading
+	var levels [6]int
+
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		h, ok := node.(*ast.Heading)
+		if !ok || !entering {
+			return ast.GoToNext
+		}
+
+		level := min(max(h.Level, 1), 6)
+		levels[level-1]++
+		for i := level; i < 6; i++ {
+			levels[i] = 0
+		}
+
+		// the renderer drops the trailing zero levels
+		last := 5
+		for last > 0 && levels[last] == 0 {
+			last--
+		}
+		numbers := make([]string, last+1)
+		for i := range numbers {
+			numbers[i] = strconv.Itoa(levels[i])
+		}
+
+		result = append(result, heading{
+			level:  level,
+			number: strings.Join(numbers, "."),
+			title:  strings.TrimSpace(nodeText(h)),
+			line:   -1,
+		})
+
+		return ast.SkipChildren
+	})
+
+	return result
+}
+
+// nodeText returns the plain text content of a node and its children
+func nodeText(node ast.Node) string {
+	var sb strings.Builder
+	ast.WalkFunc(node, func(n ast.Node, entering bool) ast.WalkStatus {
+		if leaf := n.AsLeaf(); leaf != nil && entering {
+			sb.Write(leaf.Literal)
+		}
+		return ast.GoToNext
+	})
+	return sb.String()
+}
+
+// locateHeadings finds the line of each heading in the rendered document.
+// Headings are searched in order, each one after the previous one.
+func locateHeadings(headings []heading, rendered []byte) []heading {
+	lines := strings.Split(string(rendered), "\n")
+	result := make([]heading, len(headings))
+
+	next := 0
+	for i, h := range headings {
+		h.line = -1
+		for j := next; j < len(lines); j++ {
+			stripped, _ := text.ExtractTermEscapes(lines[j])
+			if strings.HasPrefix(strings.TrimSpace(stripped), h.number+" ") {
+				h.line = j
+				next = j + 1
+				break
+			}
+		}
+		result[i] = h
+	}
+
+	return result
+}
+
+// headingAt returns the heading of the section containing the given line
+func headingAt(headings []heading, line int) (heading, bool) {
+	var result heading
+	found := false
+	for _, h := range headings {
+		if h.line < 0 {
+			continue
+		}
+		if h.line > line {
+			break
+		}
+		result = h
+		found = true
+	}
+	return result, found
+}
@@ -12,6 +12,12 @@ const helpPopupView = "helpPopup"
 type helpPopup struct {
 	active bool
 	config *Config
+	// keys assigned to several actions
+	conflicts []keyConflict
+	// first line shown and number of lines shown, the help being scrolled
+	// when it doesn't fit on the screen
+	offset int
+	height int
 }
 
 func newHelpPopup(config *Config) *helpPopup {
@@ -21,25 +27,6 @@ func newHelpPopup(config *Config) *helpPopup {
 	}
 }
 
-func (hp *helpPopup) keybindings(g *gocui.Gui) error {
-	// Keys to close the help popup
-	closeKeys := []interface{}{
-		gocui.KeyEsc,
-		gocui.KeyEnter,
-		gocui.KeySpace,
-		'q',
-		'?',
-	}
-
-	for _, key := range closeKeys {
-		if err := g.SetKeybinding(helpPopupView, key, gocui.ModNone, hp.close); err != nil {
-			return err
-		}
-	}
-
-	return nil
-}
-
 func (hp *helpPopup) layout(g *gocui.Gui) error {
 	if !hp.active {
 		return nil
@@ -47,9 +34,13 @@ func (hp *helpPopup) layout(g *gocui.Gui) error {
 
 	maxX, maxY := g.Size()
 
+	// Build the help content
+	helpContent := hp.buildHelpContent()
+
 	// Calculate popup dimensions
 	width := 60
-	height := 30
+	lines := strings.Count(helpContent, "\n")
+	height := lines + 1
 
 	// Ensure popup fits on screen
 	if width > maxX-4 {
@@ -76,11 +67,14 @@ func (hp *helpPopup) layout(g *gocui.Gui) error {
 	}
 
 	v.Clear()
-
-	// Build the help content
-	helpContent := hp.buildHelpContent()
 	fmt.Fprint(v, helpContent)
 
+	hp.height = height - 1
+	hp.offset = max(min(hp.offset, lines-hp.height), 0)
+	if err := v.SetOrigin(0, hp.offset); err != nil {
+		return err
+	}
+
 	// Set this view as current
 	if _, err := g.SetCurrentView(helpPopupView); err != nil {
 		return err
@@ -94,9 +88,22 @@ func (hp *helpPopup) buildHelpContent() string {
 
 	// Helper function to format key list
 	formatKeys := func(keys []string) string {
+		if len(keys) == 0 {
+			return "(unbound)"
+		}
 		return strings.Join(keys, ", ")
 	}
 
+	// Conflicts section, first so that it doesn't go unnoticed
+	if len(hp.conflicts) > 0 {
+		sb.WriteString(" CONFLICTS\n")
+		sb.WriteString(" ═══════════════════════════════════════════════\n")
+		for _, c := range hp.conflicts {
+			sb.WriteString(fmt.Sprintf("  %-20s %s, not %s\n", formatKeys(c.keyStrs), c.winner, formatKeys(losers(c))))
+		}
+		sb.WriteString("\n")
+	}
+
 	// Navigation section
 	sb.WriteString(" NAVIGATION\n")
 	sb.WriteString(" ═══════════════════════════════════════════════\n")
@@ -108,6 +115,22 @@ func (hp *helpPopup) buildHelpContent() string {
 	sb.WriteString(fmt.Sprintf("  %-20s Page down\n", formatKeys(hp.config.Keybindings.PageDown)))
 	sb.WriteString(fmt.Sprintf("  %-20s Go to top\n", formatKeys(hp.config.Keybindings.GoToTop)))
 	sb.WriteString(fmt.Sprintf("  %-20s Go to bottom\n", formatKeys(hp.config.Keybindings.GoToBottom)))
+	sb.WriteString(fmt.Sprintf("  %-20s Cycle content width\n", formatKeys(hp.config.Keybindings.CycleWidth)))
+	sb.WriteString("\n")
+
+	// Marks section
+	markKeys := func(keys []string) string {
+		withName := make([]string, len(keys))
+		for i, key := range keys {
+			withName[i] = key + "{a-z}"
+		}
+		return formatKeys(withName)
+	}
+	sb.WriteString(" MARKS\n")
+	sb.WriteString(" ═══════════════════════════════════════════════\n")
+	sb.WriteString(fmt.Sprintf("  %-20s Set a mark\n", markKeys(hp.config.Keybindings.SetMark)))
+	sb.WriteString(fmt.Sprintf("  %-20s Jump to a mark\n", markKeys(hp.config.Keybindings.JumpToMark)))
+	sb.WriteString(fmt.Sprintf("  %-20s List marks\n", formatKeys(hp.config.Keybindings.ShowMarks)))
 	sb.WriteString("\n")
 
 	// Search section
@@ -123,6 +146,7 @@ func (hp *helpPopup) buildHelpContent() string {
 	sb.WriteString(" GENERAL\n")
 	sb.WriteString(" ═══════════════════════════════════════════════\n")
 	sb.WriteString(fmt.Sprintf("  %-20s Show this help\n", formatKeys(hp.config.Keybindings.ShowHelp)))
+	sb.WriteString(fmt.Sprintf("  %-20s Toggle status line\n", formatKeys(hp.config.Keybindings.ToggleStatus)))
 	sb.WriteString(fmt.Sprintf("  %-20s Quit\n", formatKeys(hp.config.Keybindings.Quit)))
 	sb.WriteString("\n")
 
@@ -134,14 +158,36 @@ func (hp *helpPopup) buildHelpContent() string {
 	sb.WriteString("    (i=up, e=down, o=right)\n")
 	sb.WriteString("  • Search is case-insensitive\n")
 	sb.WriteString("  • While searching:\n")
-	sb.WriteString("    - Enter to execute search\n")
-	sb.WriteString("    - ESC or Ctrl+C to cancel\n")
+	sb.WriteString(fmt.Sprintf("    - %s to execute search\n", formatKeys(hp.config.Keybindings.Search.Execute)))
+	sb.WriteString(fmt.Sprintf("    - %s to cancel\n", formatKeys(hp.config.Keybindings.Search.Cancel)))
+	sb.WriteString("  • In this help:\n")
+	sb.WriteString(fmt.Sprintf("    - %s to scroll down\n", formatKeys(hp.config.Keybindings.Help.ScrollDown)))
+	sb.WriteString(fmt.Sprintf("    - %s to scroll up\n", formatKeys(hp.config.Keybindings.Help.ScrollUp)))
+	sb.WriteString(fmt.Sprintf("    - %s to scroll a page down\n", formatKeys(hp.config.Keybindings.Help.PageDown)))
+	sb.WriteString(fmt.Sprintf("    - %s to scroll a page up\n", formatKeys(hp.config.Keybindings.Help.PageUp)))
 
 	return sb.String()
 }
 
 func (hp *helpPopup) show() {
 	hp.active = true
+	hp.offset = 0
+}
+
+// scrollLines scrolls the help by a number of lines, up when negative. The
+// offset is clamped by the layout.
+func (hp *helpPopup) scrollLines(lines int) func(*gocui.Gui, *gocui.View) error {
+	return func(g *gocui.Gui, v *gocui.View) error {
+		hp.offset = max(hp.offset+lines, 0)
+		return nil
+	}
+}
+
+// scrollPages scrolls the help by a number of pages, up when negative
+func (hp *helpPopup) scrollPages(pages int) func(*gocui.Gui, *gocui.View) error {
+	return func(g *gocui.Gui, v *gocui.View) error {
+		return hp.scrollLines(pages*max(hp.height-1, 1))(g, v)
+	}
 }
 
 func (hp *helpPopup) close(g *gocui.Gui, v *gocui.View) error {
@@ -158,4 +204,15 @@ func (hp *helpPopup) close(g *gocui.Gui, v *gocui.View) error {
 
 func (hp *helpPopup) isActive() bool {
 	return hp.active
-}
\ No newline at end of file
+}
+
+// losers returns the actions of a conflict that don't get the key
+func losers(c keyConflict) []string {
+	var result []string
+	for _, action := range c.actions {
+		if action != c.winner {
+			result = append(result, action)
+		}
+	}
+	return result
+}
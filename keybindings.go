@@ -1,100 +1,713 @@
 package main
 
 import (
+	"fmt"
 	"strings"
-	
+	"time"
+	"unicode"
+	"unicode/utf8"
+
 	"github.com/awesome-gocui/gocui"
 )
 
+// keyStroke is a single key press, as understood by gocui
+type keyStroke struct {
+	key interface{}
+	mod gocui.Modifier
+}
+
+// keybinding binds a sequence of one or more key strokes to a handler
 type keybinding struct {
 	viewName string
-	key      interface{}
-	mod      gocui.Modifier
+	keys     []keyStroke
 	handler  func(*gocui.Gui, *gocui.View) error
+
+	// where the keybinding comes from, empty for fixed keybindings
+	action string
+	keyStr string
+}
+
+// id identifies the key sequence of the keybinding and its view
+func (kb keybinding) id() string {
+	return fmt.Sprintf("%s%v", kb.viewName, kb.keys)
+}
+
+// singleKey creates a keybinding for a single key stroke
+func singleKey(viewName string, key interface{}, handler func(*gocui.Gui, *gocui.View) error) keybinding {
+	return keybinding{
+		viewName: viewName,
+		keys:     []keyStroke{{key: key, mod: gocui.ModNone}},
+		handler:  handler,
+	}
+}
+
+// keyDispatcher routes the key strokes received from gocui to the keybindings
+// of each view. gocui only knows about single keys, so every key stroke used
+// in a view is registered with gocui and handed to a keySequencer for that
+// view, which in turn runs the bindings of complete sequences.
+type keyDispatcher struct {
+	// how long to wait for the next stroke of an ambiguous sequence
+	timeout time.Duration
+	// views accepting a count before a sequence, like "5 j"
+	countViews map[string]bool
+
+	sequencers map[string]*keySequencer
+
+	// count typed before the sequence being handled, 0 if none
+	count int
+}
+
+func newKeyDispatcher(timeout time.Duration, countViews ...string) *keyDispatcher {
+	d := &keyDispatcher{
+		timeout:    timeout,
+		countViews: make(map[string]bool),
+		sequencers: make(map[string]*keySequencer),
+	}
+	for _, view := range countViews {
+		d.countViews[view] = true
+	}
+	return d
+}
+
+// register registers the keybindings with gocui
+func (d *keyDispatcher) register(g *gocui.Gui, bindings []keybinding) error {
+	var views []string
+
+	for _, kb := range bindings {
+		s, ok := d.sequencers[kb.viewName]
+		if !ok {
+			s = &keySequencer{dispatcher: d, counts: d.countViews[kb.viewName]}
+			d.sequencers[kb.viewName] = s
+			views = append(views, kb.viewName)
+		}
+		s.bindings = append(s.bindings, kb)
+	}
+
+	for _, view := range views {
+		s := d.sequencers[view]
+		registered := make(map[keyStroke]bool)
+
+		strokes := []keyStroke{}
+		for _, kb := range s.bindings {
+			strokes = append(strokes, kb.keys...)
+		}
+		if s.counts {
+			for digit := '0'; digit <= '9'; digit++ {
+				strokes = append(strokes, keyStroke{key: digit, mod: gocui.ModNone})
+			}
+		}
+
+		for _, stroke := range strokes {
+			if registered[stroke] {
+				continue
+			}
+			registered[stroke] = true
+
+			// gocui only reports mouse events when asked to
+			if isMouseKey(stroke.key) {
+				g.Mouse = true
+			}
+
+			if err := g.SetKeybinding(view, stroke.key, stroke.mod, s.handlerFor(stroke)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// unregister removes the keybindings from gocui
+func (d *keyDispatcher) unregister(g *gocui.Gui) {
+	for view, s := range d.sequencers {
+		s.reset()
+		g.DeleteKeybindings(view)
+	}
+}
+
+// repeat returns the count typed before the sequence being handled, or 1
+func (d *keyDispatcher) repeat() int {
+	if d.count > 0 {
+		return d.count
+	}
+	return 1
+}
+
+// maxKeyCount bounds the count typed before a sequence, big enough for a line
+// number and small enough for the count not to overflow
+const maxKeyCount = 1000000
+
+// keySequencer buffers the key strokes of a view until they form one of the
+// sequences bound in that view.
+//
+// When a sequence is also the beginning of a longer one (like "g" and "g g"),
+// the sequencer waits for the next stroke, up to the dispatcher timeout. If
+// that stroke doesn't continue any sequence, or if none comes in time, the
+// shorter sequence is run and the stroke is handled anew.
+type keySequencer struct {
+	dispatcher *keyDispatcher
+	bindings   []keybinding
+	pending    []keyStroke
+
+	// longest complete sequence in pending, run if no longer one completes
+	fallback *keybinding
+
+	// count prefix support, and the count typed so far
+	counts bool
+	count  int
+
+	// incremented on each stroke, to detect stale timeouts
+	generation int
+}
+
+func (s *keySequencer) handlerFor(stroke keyStroke) func(*gocui.Gui, *gocui.View) error {
+	return func(g *gocui.Gui, v *gocui.View) error {
+		s.generation++
+		return s.feed(g, v, stroke)
+	}
+}
+
+func (s *keySequencer) feed(g *gocui.Gui, v *gocui.View, stroke keyStroke) error {
+	// Digits before a sequence form its count, unless bound themselves. A
+	// leading 0 is never a count.
+	if s.counts && len(s.pending) == 0 && !s.starts(stroke) {
+		if digit, ok := stroke.key.(rune); ok && stroke.mod == gocui.ModNone &&
+			digit >= '0' && digit <= '9' && (digit != '0' || s.count > 0) {
+			s.count = min(s.count*10+int(digit-'0'), maxKeyCount)
+			return nil
+		}
+	}
+
+	s.pending = append(s.pending, stroke)
+
+	var exact *keybinding
+	longer := false
+	for i := range s.bindings {
+		kb := &s.bindings[i]
+		if !hasKeyPrefix(kb.keys, s.pending) {
+			continue
+		}
+		if len(kb.keys) == len(s.pending) {
+			if exact == nil {
+				exact = kb
+			}
+		} else {
+			longer = true
+		}
+	}
+
+	if longer {
+		// wait for the next stroke
+		if exact != nil {
+			s.fallback = exact
+		}
+		s.waitNext(g, v)
+		return nil
+	}
+
+	if exact != nil {
+		return s.run(g, v, exact)
+	}
+
+	// Dead end: run the longest complete sequence typed so far, if any,
+	// and handle the strokes that followed it again.
+	pending, fallback := s.pending, s.fallback
+
+	if fallback != nil {
+		if err := s.run(g, v, fallback); err != nil {
+			return err
+		}
+		for _, stroke := range pending[len(fallback.keys):] {
+			if err := s.feed(g, v, stroke); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	s.reset()
+
+	// Nothing matched, but the last stroke may start a new sequence
+	if len(pending) > 1 {
+		return s.feed(g, v, stroke)
+	}
+
+	return nil
+}
+
+// starts tells if a sequence of the view starts with the given stroke
+func (s *keySequencer) starts(stroke keyStroke) bool {
+	for _, kb := range s.bindings {
+		if kb.keys[0] == stroke {
+			return true
+		}
+	}
+	return false
+}
+
+// run runs the handler of a complete sequence with the typed count
+func (s *keySequencer) run(g *gocui.Gui, v *gocui.View, kb *keybinding) error {
+	count := s.count
+	s.reset()
+
+	s.dispatcher.count = count
+	defer func() { s.dispatcher.count = 0 }()
+
+	return kb.handler(g, v)
+}
+
+// waitNext arms the timeout of an incomplete sequence
+func (s *keySequencer) waitNext(g *gocui.Gui, v *gocui.View) {
+	if s.dispatcher.timeout <= 0 {
+		return
+	}
+
+	generation := s.generation
+	time.AfterFunc(s.dispatcher.timeout, func() {
+		g.Update(func(g *gocui.Gui) error {
+			// another stroke came in the meantime
+			if s.generation != generation {
+				return nil
+			}
+			return s.expire(g, v)
+		})
+	})
+}
+
+// expire gives up waiting for the rest of a sequence
+func (s *keySequencer) expire(g *gocui.Gui, v *gocui.View) error {
+	pending, fallback := s.pending, s.fallback
+	if fallback == nil {
+		s.reset()
+		return nil
+	}
+
+	if err := s.run(g, v, fallback); err != nil {
+		return err
+	}
+	for _, stroke := range pending[len(fallback.keys):] {
+		if err := s.feed(g, v, stroke); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *keySequencer) reset() {
+	s.pending = nil
+	s.fallback = nil
+	s.count = 0
+}
+
+// hasKeyPrefix tells if the key sequence starts with the given prefix
+func hasKeyPrefix(keys []keyStroke, prefix []keyStroke) bool {
+	if len(prefix) > len(keys) {
+		return false
+	}
+	for i := range prefix {
+		if keys[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// ctrlKeys maps the names of the keys usable with Control to gocui keys
+var ctrlKeys = map[string]gocui.Key{
+	"a": gocui.KeyCtrlA, "b": gocui.KeyCtrlB, "c": gocui.KeyCtrlC, "d": gocui.KeyCtrlD,
+	"e": gocui.KeyCtrlE, "f": gocui.KeyCtrlF, "g": gocui.KeyCtrlG, "h": gocui.KeyCtrlH,
+	"i": gocui.KeyCtrlI, "j": gocui.KeyCtrlJ, "k": gocui.KeyCtrlK, "l": gocui.KeyCtrlL,
+	"m": gocui.KeyCtrlM, "n": gocui.KeyCtrlN, "o": gocui.KeyCtrlO, "p": gocui.KeyCtrlP,
+	"q": gocui.KeyCtrlQ, "r": gocui.KeyCtrlR, "s": gocui.KeyCtrlS, "t": gocui.KeyCtrlT,
+	"u": gocui.KeyCtrlU, "v": gocui.KeyCtrlV, "w": gocui.KeyCtrlW, "x": gocui.KeyCtrlX,
+	"y": gocui.KeyCtrlY, "z": gocui.KeyCtrlZ,
+
+	"2": gocui.KeyCtrl2, "@": gocui.KeyCtrl2, "~": gocui.KeyCtrl2, "space": gocui.KeyCtrlSpace,
+	"3": gocui.KeyCtrl3, "[": gocui.KeyCtrlLsqBracket,
+	"4": gocui.KeyCtrl4, "\\": gocui.KeyCtrlBackslash,
+	"5": gocui.KeyCtrl5, "]": gocui.KeyCtrlRsqBracket,
+	"6": gocui.KeyCtrl6, "^": gocui.KeyCtrl6,
+	"7": gocui.KeyCtrl7, "/": gocui.KeyCtrlSlash, "_": gocui.KeyCtrlUnderscore,
+	"8": gocui.KeyCtrl8,
+}
+
+// namedKeys maps the lowercase names of the special keys to gocui keys
+var namedKeys = map[string]gocui.Key{
+	"up": gocui.KeyArrowUp, "arrowup": gocui.KeyArrowUp,
+	"down": gocui.KeyArrowDown, "arrowdown": gocui.KeyArrowDown,
+	"left": gocui.KeyArrowLeft, "arrowleft": gocui.KeyArrowLeft,
+	"right": gocui.KeyArrowRight, "arrowright": gocui.KeyArrowRight,
+	"pageup": gocui.KeyPgup, "pgup": gocui.KeyPgup,
+	"pagedown": gocui.KeyPgdn, "pgdn": gocui.KeyPgdn, "pagedn": gocui.KeyPgdn,
+	"space": gocui.KeySpace,
+	"enter": gocui.KeyEnter, "return": gocui.KeyEnter,
+	"escape": gocui.KeyEsc, "esc": gocui.KeyEsc,
+	"tab":       gocui.KeyTab,
+	"backspace": gocui.KeyBackspace, "backspace2": gocui.KeyBackspace2,
+	"insert": gocui.KeyInsert, "ins": gocui.KeyInsert,
+	"delete": gocui.KeyDelete, "del": gocui.KeyDelete,
+	"home": gocui.KeyHome,
+	"end":  gocui.KeyEnd,
+
+	"f1": gocui.KeyF1, "f2": gocui.KeyF2, "f3": gocui.KeyF3, "f4": gocui.KeyF4,
+	"f5": gocui.KeyF5, "f6": gocui.KeyF6, "f7": gocui.KeyF7, "f8": gocui.KeyF8,
+	"f9": gocui.KeyF9, "f10": gocui.KeyF10, "f11": gocui.KeyF11, "f12": gocui.KeyF12,
+
+	"mouseleft":    gocui.MouseLeft,
+	"mousemiddle":  gocui.MouseMiddle,
+	"mouseright":   gocui.MouseRight,
+	"mouserelease": gocui.MouseRelease,
+	"mousewheelup": gocui.MouseWheelUp, "wheelup": gocui.MouseWheelUp,
+	"mousewheeldown": gocui.MouseWheelDown, "wheeldown": gocui.MouseWheelDown,
 }
 
-func (k *keybinding) Register(g *gocui.Gui) error {
-	return g.SetKeybinding(k.viewName, k.key, k.mod, k.handler)
+// isMouseKey tells if a gocui key is a mouse button
+func isMouseKey(key interface{}) bool {
+	switch key {
+	case gocui.MouseLeft, gocui.MouseMiddle, gocui.MouseRight,
+		gocui.MouseRelease, gocui.MouseWheelUp, gocui.MouseWheelDown:
+		return true
+	}
+	return false
 }
 
-// parseKey converts a string key representation to gocui key and modifier
-func parseKey(keyStr string) (interface{}, gocui.Modifier) {
+// parseKey converts a string key representation to gocui key and modifier.
+//
+// A key is a single character, or the name of a special key (case
+// insensitive) like "Up", "PageDown", "F1" or "MouseLeft". It can be prefixed
+// with modifiers: "C-" or "Ctrl-" for Control, "M-", "Alt-" or "Meta-" for
+// Alt, and "S-" or "Shift-" for Shift, as in "C-d", "M-x" or "S-g".
+func parseKey(keyStr string) (interface{}, gocui.Modifier, error) {
+	var ctrl, alt, shift bool
+
+	name := keyStr
+	for {
+		prefix, ok := modifierPrefix(name)
+		if !ok {
+			break
+		}
+		switch prefix {
+		case "c-", "ctrl-":
+			ctrl = true
+		case "m-", "alt-", "meta-":
+			alt = true
+		case "s-", "shift-":
+			shift = true
+		}
+		name = name[len(prefix):]
+	}
+
+	mod := gocui.ModNone
+	if alt {
+		mod = gocui.ModAlt
+	}
+
+	if name == "" {
+		return nil, gocui.ModNone, fmt.Errorf("empty key")
+	}
+
 	// Handle control key combinations
-	if strings.HasPrefix(keyStr, "C-") || strings.HasPrefix(keyStr, "Ctrl-") {
-		keyStr = strings.TrimPrefix(keyStr, "C-")
-		keyStr = strings.TrimPrefix(keyStr, "Ctrl-")
-		
-		// Handle special control combinations
-		switch strings.ToLower(keyStr) {
-		case "c":
-			return gocui.KeyCtrlC, gocui.ModNone
-		case "f":
-			return gocui.KeyCtrlF, gocui.ModNone
-		case "n":
-			return gocui.KeyCtrlN, gocui.ModNone
-		case "p":
-			return gocui.KeyCtrlP, gocui.ModNone
-		case "g":
-			return gocui.KeyCtrlG, gocui.ModNone
-		}
-	}
-	
+	if ctrl {
+		if shift {
+			return nil, gocui.ModNone, fmt.Errorf("Control and Shift can't be combined")
+		}
+		if key, ok := ctrlKeys[strings.ToLower(name)]; ok {
+			return key, mod, nil
+		}
+		return nil, gocui.ModNone, fmt.Errorf("unknown Control combination %q", name)
+	}
+
+	// Handle shifted letters, Shift-Tab is handled by parseKeyStrokes
+	if shift {
+		r, size := utf8.DecodeRuneInString(name)
+		if size == len(name) && unicode.IsLetter(r) {
+			return unicode.ToUpper(r), mod, nil
+		}
+		return nil, gocui.ModNone, fmt.Errorf("Shift can only be combined with letters and Tab")
+	}
+
 	// Handle special keys
-	switch keyStr {
-	case "Up", "ArrowUp":
-		return gocui.KeyArrowUp, gocui.ModNone
-	case "Down", "ArrowDown":
-		return gocui.KeyArrowDown, gocui.ModNone
-	case "Left", "ArrowLeft":
-		return gocui.KeyArrowLeft, gocui.ModNone
-	case "Right", "ArrowRight":
-		return gocui.KeyArrowRight, gocui.ModNone
-	case "PageUp", "PgUp":
-		return gocui.KeyPgup, gocui.ModNone
-	case "PageDown", "PgDn", "PageDn":
-		return gocui.KeyPgdn, gocui.ModNone
-	case "Space", " ":
-		return gocui.KeySpace, gocui.ModNone
-	case "Enter", "Return":
-		return gocui.KeyEnter, gocui.ModNone
-	case "Escape", "Esc":
-		return gocui.KeyEsc, gocui.ModNone
-	case "Tab":
-		return gocui.KeyTab, gocui.ModNone
-	case "Backspace":
-		return gocui.KeyBackspace, gocui.ModNone
-	case "Delete", "Del":
-		return gocui.KeyDelete, gocui.ModNone
-	case "Home":
-		return gocui.KeyHome, gocui.ModNone
-	case "End":
-		return gocui.KeyEnd, gocui.ModNone
-	}
-	
+	if name == " " {
+		return gocui.KeySpace, mod, nil
+	}
+	if key, ok := namedKeys[strings.ToLower(name)]; ok {
+		if alt && isMouseKey(key) {
+			return nil, gocui.ModNone, fmt.Errorf("mouse buttons can't have modifiers")
+		}
+		return key, mod, nil
+	}
+
 	// Handle single character keys
-	if len(keyStr) == 1 {
-		return rune(keyStr[0]), gocui.ModNone
+	if r, size := utf8.DecodeRuneInString(name); size == len(name) && r != utf8.RuneError {
+		return r, mod, nil
 	}
-	
-	// Default fallback
-	return nil, gocui.ModNone
+
+	return nil, gocui.ModNone, fmt.Errorf("unknown key %q", name)
 }
 
-// createKeybindingFromStrings creates keybindings from string representations
-func createKeybindingsFromStrings(viewName string, keys []string, handler func(*gocui.Gui, *gocui.View) error) []keybinding {
-	var bindings []keybinding
-	
+// modifierPrefix returns the lowercase modifier prefix of a key, if any. A
+// lone "-" or a name ending with a modifier, like "C-", has no prefix.
+func modifierPrefix(keyStr string) (string, bool) {
+	lower := strings.ToLower(keyStr)
+	for _, prefix := range []string{"ctrl-", "alt-", "meta-", "shift-", "c-", "m-", "s-"} {
+		if strings.HasPrefix(lower, prefix) && len(lower) > len(prefix) {
+			return prefix, true
+		}
+	}
+	return "", false
+}
+
+// parseKeyStrokes converts a single key to the strokes gocui reports for it.
+//
+// gocui always reads the terminal in escape mode, where Alt is received as
+// Escape followed by the key, and doesn't know about Shift-Tab, that
+// terminals send as "Escape [ Z". Those are turned into sequences, which
+// means that Escape alone waits for the sequence timeout when they're bound.
+func parseKeyStrokes(keyStr string) ([]keyStroke, error) {
+	if isBacktab(keyStr) {
+		return []keyStroke{
+			{key: gocui.KeyEsc, mod: gocui.ModNone},
+			{key: '[', mod: gocui.ModNone},
+			{key: 'Z', mod: gocui.ModNone},
+		}, nil
+	}
+
+	key, mod, err := parseKey(keyStr)
+	if err != nil {
+		return nil, err
+	}
+
+	if mod == gocui.ModAlt {
+		return []keyStroke{
+			{key: gocui.KeyEsc, mod: gocui.ModNone},
+			{key: key, mod: gocui.ModNone},
+		}, nil
+	}
+
+	return []keyStroke{{key: key, mod: mod}}, nil
+}
+
+// isBacktab tells if a key is Shift-Tab
+func isBacktab(keyStr string) bool {
+	if strings.EqualFold(keyStr, "Backtab") {
+		return true
+	}
+	prefix, ok := modifierPrefix(keyStr)
+	return ok && (prefix == "s-" || prefix == "shift-") && strings.EqualFold(keyStr[len(prefix):], "Tab")
+}
+
+// leaderToken is replaced by the leader key in key sequences
+const leaderToken = "<leader>"
+
+// parseKeySequence converts a space separated sequence of keys, like "g g"
+// or "<leader> t", to key strokes. A "<leader>" token stands for the leader
+// key and can be glued to the following key, as in "<leader>t".
+func parseKeySequence(seqStr string, leader string) ([]keyStroke, error) {
+	tokens := strings.Fields(seqStr)
+	if len(tokens) == 0 {
+		// " " is the space key, not an empty sequence
+		tokens = []string{seqStr}
+	}
+
+	var result []keyStroke
+	for _, token := range tokens {
+		if strings.HasPrefix(token, leaderToken) {
+			if leader == "" {
+				return nil, fmt.Errorf("no leader key defined")
+			}
+			strokes, err := parseKeyStrokes(leader)
+			if err != nil {
+				return nil, fmt.Errorf("leader key: %v", err)
+			}
+			result = append(result, strokes...)
+
+			token = strings.TrimPrefix(token, leaderToken)
+			if token == "" {
+				continue
+			}
+		}
+
+		strokes, err := parseKeyStrokes(token)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, strokes...)
+	}
+
+	return result, nil
+}
+
+// keyError reports a key of the config that can't be parsed
+type keyError struct {
+	action string
+	key    string
+	err    error
+}
+
+func (e keyError) Error() string {
+	return fmt.Sprintf("%s: invalid key %q: %v", e.action, e.key, e.err)
+}
+
+// keyErrors reports all the keys of the config that can't be parsed
+type keyErrors []keyError
+
+func (errs keyErrors) Error() string {
+	lines := make([]string, len(errs))
+	for i, err := range errs {
+		lines[i] = "  " + err.Error()
+	}
+	return "invalid keybindings:\n" + strings.Join(lines, "\n")
+}
+
+// keybindingBuilder creates keybindings from their string representation in
+// the config, collecting the keys that can't be parsed.
+type keybindingBuilder struct {
+	leader   string
+	bindings []keybinding
+	errs     keyErrors
+}
+
+// add binds the keys of an action to a handler
+func (b *keybindingBuilder) add(viewName string, action string, keys []string, handler func(*gocui.Gui, *gocui.View) error) {
 	for _, keyStr := range keys {
-		key, mod := parseKey(keyStr)
-		if key != nil {
-			bindings = append(bindings, keybinding{
+		strokes, err := parseKeySequence(keyStr, b.leader)
+		if err != nil {
+			b.errs = append(b.errs, keyError{action: action, key: keyStr, err: err})
+			continue
+		}
+		b.bindings = append(b.bindings, keybinding{
+			viewName: viewName,
+			keys:     strokes,
+			handler:  handler,
+			action:   action,
+			keyStr:   keyStr,
+		})
+	}
+}
+
+// addNamed binds the keys of an action followed by a name from a to z, like
+// "m a", to the handler for that name
+func (b *keybindingBuilder) addNamed(viewName string, action string, keys []string, handler func(name rune) func(*gocui.Gui, *gocui.View) error) {
+	for _, keyStr := range keys {
+		strokes, err := parseKeySequence(keyStr, b.leader)
+		if err != nil {
+			b.errs = append(b.errs, keyError{action: action, key: keyStr, err: err})
+			continue
+		}
+		for name := 'a'; name <= 'z'; name++ {
+			b.bindings = append(b.bindings, keybinding{
 				viewName: viewName,
-				key:      key,
-				mod:      mod,
-				handler:  handler,
+				keys:     append(append([]keyStroke{}, strokes...), keyStroke{key: name, mod: gocui.ModNone}),
+				handler:  handler(name),
+				action:   action,
+				keyStr:   fmt.Sprintf("%s %c", keyStr, name),
+			})
+		}
+	}
+}
+
+// err returns the parsing errors, if any
+func (b *keybindingBuilder) err() error {
+	if len(b.errs) == 0 {
+		return nil
+	}
+	return b.errs
+}
+
+// keyConflict is a key sequence bound to several actions in the same view
+type keyConflict struct {
+	id       string
+	viewName string
+	keyStrs  []string
+	actions  []string
+	// the action keeping the key sequence
+	winner string
+}
+
+func (c keyConflict) String() string {
+	return fmt.Sprintf("key %s is assigned to %s, %s is used",
+		strings.Join(quoteAll(c.keyStrs), " and "), strings.Join(c.actions, " and "), c.winner)
+}
+
+// findConflicts finds the key sequences bound to more than one action in the
+// same view. Fixed keybindings are ignored.
+//
+// The key sequence goes to the first action whose keys are customized, or to
+// the first action if none is. Actions come in the order of the keybindings.
+func findConflicts(bindings []keybinding, custom func(action string) bool) []keyConflict {
+	var result []keyConflict
+	index := make(map[string]int)
+
+	for _, kb := range bindings {
+		if kb.action == "" {
+			continue
+		}
+
+		i, ok := index[kb.id()]
+		if !ok {
+			index[kb.id()] = len(result)
+			result = append(result, keyConflict{
+				id:       kb.id(),
+				viewName: kb.viewName,
+				keyStrs:  []string{kb.keyStr},
+				actions:  []string{kb.action},
+				winner:   kb.action,
 			})
+			continue
 		}
+
+		c := &result[i]
+		if !containsString(c.actions, kb.action) {
+			c.actions = append(c.actions, kb.action)
+			if custom(kb.action) && !custom(c.winner) {
+				c.winner = kb.action
+			}
+		}
+		if !containsString(c.keyStrs, kb.keyStr) {
+			c.keyStrs = append(c.keyStrs, kb.keyStr)
+		}
+	}
+
+	conflicts := result[:0]
+	for _, c := range result {
+		if len(c.actions) > 1 {
+			conflicts = append(conflicts, c)
+		}
+	}
+	return conflicts
+}
+
+// resolveConflicts removes the keybindings losing a conflict
+func resolveConflicts(bindings []keybinding, conflicts []keyConflict) []keybinding {
+	winners := make(map[string]string, len(conflicts))
+	for _, c := range conflicts {
+		winners[c.id] = c.winner
+	}
+
+	result := make([]keybinding, 0, len(bindings))
+	for _, kb := range bindings {
+		winner, conflicting := winners[kb.id()]
+		if conflicting && kb.action != "" && kb.action != winner {
+			continue
+		}
+		result = append(result, kb)
+	}
+	return result
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func quoteAll(list []string) []string {
+	result := make([]string, len(list))
+	for i, s := range list {
+		result[i] = fmt.Sprintf("%q", s)
 	}
-	
-	return bindings
+	return result
 }
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/awesome-gocui/gocui"
+)
+
+// newTestSequencer creates a sequencer of the given sequences of runes, each
+// run recorded as the sequence and its count, like "j×5"
+func newTestSequencer(runs *[]string, sequences ...string) *keySequencer {
+	d := newKeyDispatcher(0, renderView)
+	s := &keySequencer{dispatcher: d, counts: true}
+	for _, seq := range sequences {
+		seq := seq
+		var keys []keyStroke
+		for _, r := range strings.Fields(seq) {
+			keys = append(keys, keyStroke{key: []rune(r)[0], mod: gocui.ModNone})
+		}
+		s.bindings = append(s.bindings, keybinding{
+			viewName: renderView,
+			keys:     keys,
+			handler: func(*gocui.Gui, *gocui.View) error {
+				*runs = append(*runs, fmt.Sprintf("%s×%d", seq, d.repeat()))
+				return nil
+			},
+		})
+	}
+	return s
+}
+
+func TestKeySequencerFeed(t *testing.T) {
+	tests := []struct {
+		name      string
+		sequences []string
+		// typed runes, "|" standing for the timeout of a pending sequence
+		typed    string
+		expected []string
+	}{
+		{"single key", []string{"j"}, "jj", []string{"j×1", "j×1"}},
+		{"count", []string{"j"}, "5j", []string{"j×5"}},
+		{"count of several digits", []string{"j"}, "12j", []string{"j×12"}},
+		{"count reset after a run", []string{"j"}, "3jj", []string{"j×3", "j×1"}},
+		{"leading zero is not a count", []string{"j"}, "0j", []string{"j×1"}},
+		{"bound digit is not a count", []string{"j", "0"}, "0j", []string{"0×1", "j×1"}},
+		{"huge count is clamped", []string{"j"}, "99999999999999999999j", []string{fmt.Sprintf("j×%d", maxKeyCount)}},
+		{"sequence", []string{"g g"}, "gg", []string{"g g×1"}},
+		{"count before a sequence", []string{"g g"}, "4gg", []string{"g g×4"}},
+		{"unknown stroke starts a new sequence", []string{"g g", "j"}, "gj", []string{"j×1"}},
+		{"ambiguous prefix completed", []string{"g", "g g"}, "gg", []string{"g g×1"}},
+		{"ambiguous prefix then another key", []string{"g", "g g", "j"}, "gj", []string{"g×1", "j×1"}},
+		{"ambiguous prefix on timeout", []string{"g", "g g"}, "g|", []string{"g×1"}},
+		{"count kept for the fallback", []string{"g", "g g", "j"}, "3gj", []string{"g×3", "j×1"}},
+		{"timeout drops an incomplete sequence", []string{"g g", "j"}, "2g|j", []string{"j×1"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var runs []string
+			s := newTestSequencer(&runs, test.sequences...)
+			for _, r := range test.typed {
+				var err error
+				if r == '|' {
+					err = s.expire(nil, nil)
+				} else {
+					err = s.feed(nil, nil, keyStroke{key: r, mod: gocui.ModNone})
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if !reflect.DeepEqual(runs, test.expected) {
+				t.Errorf("got %v, expected %v", runs, test.expected)
+			}
+		})
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	tests := []struct {
+		key   string
+		code  interface{}
+		mod   gocui.Modifier
+		error bool
+	}{
+		{key: "j", code: 'j'},
+		{key: "?", code: '?'},
+		{key: "é", code: 'é'},
+		{key: "Up", code: gocui.KeyArrowUp},
+		{key: "pagedown", code: gocui.KeyPgdn},
+		{key: "F1", code: gocui.KeyF1},
+		{key: " ", code: gocui.KeySpace},
+		{key: "C-f", code: gocui.KeyCtrlF},
+		{key: "Ctrl-F", code: gocui.KeyCtrlF},
+		{key: "M-x", code: 'x', mod: gocui.ModAlt},
+		{key: "Alt-x", code: 'x', mod: gocui.ModAlt},
+		{key: "Meta-Enter", code: gocui.KeyEnter, mod: gocui.ModAlt},
+		{key: "S-g", code: 'G'},
+		{key: "Shift-a", code: 'A'},
+		{key: "-", code: '-'},
+		{key: "C-", error: true},
+		{key: "", error: true},
+		{key: "bogus", error: true},
+		{key: "C-bogus", error: true},
+		{key: "C-S-x", error: true},
+		{key: "S-1", error: true},
+		{key: "M-MouseLeft", error: true},
+	}
+
+	for _, test := range tests {
+		code, mod, err := parseKey(test.key)
+		switch {
+		case test.error && err == nil:
+			t.Errorf("%q: expected an error, got %v", test.key, code)
+		case !test.error && err != nil:
+			t.Errorf("%q: unexpected error: %v", test.key, err)
+		case !test.error && (code != test.code || mod != test.mod):
+			t.Errorf("%q: got %v %v, expected %v %v", test.key, code, mod, test.code, test.mod)
+		}
+	}
+}
+
+func TestParseKeySequence(t *testing.T) {
+	char := func(r rune) keyStroke { return keyStroke{key: r, mod: gocui.ModNone} }
+	esc := keyStroke{key: gocui.KeyEsc, mod: gocui.ModNone}
+	backtab := []keyStroke{esc, char('['), char('Z')}
+
+	tests := []struct {
+		sequence string
+		leader   string
+		expected []keyStroke
+		error    bool
+	}{
+		{sequence: "g g", expected: []keyStroke{char('g'), char('g')}},
+		{sequence: " ", expected: []keyStroke{{key: gocui.KeySpace, mod: gocui.ModNone}}},
+		{sequence: "C-x C-s", expected: []keyStroke{{key: gocui.KeyCtrlX}, {key: gocui.KeyCtrlS}}},
+
+		// Alt is received as Escape followed by the key
+		{sequence: "M-x", expected: []keyStroke{esc, char('x')}},
+		{sequence: "g Alt-g", expected: []keyStroke{char('g'), esc, char('g')}},
+
+		// Shift-Tab is received as Escape [ Z
+		{sequence: "S-Tab", expected: backtab},
+		{sequence: "Shift-Tab", expected: backtab},
+		{sequence: "Backtab", expected: backtab},
+
+		{sequence: "<leader> t", leader: "\\", expected: []keyStroke{char('\\'), char('t')}},
+		{sequence: "<leader>t", leader: ",", expected: []keyStroke{char(','), char('t')}},
+		{sequence: "<leader> <leader>", leader: "Space", expected: []keyStroke{{key: gocui.KeySpace}, {key: gocui.KeySpace}}},
+		{sequence: "<leader> t", leader: "M-l", expected: []keyStroke{esc, char('l'), char('t')}},
+		{sequence: "<leader> t", leader: "", error: true},
+		{sequence: "<leader> t", leader: "bogus", error: true},
+		{sequence: "g bogus", error: true},
+	}
+
+	for _, test := range tests {
+		strokes, err := parseKeySequence(test.sequence, test.leader)
+		switch {
+		case test.error && err == nil:
+			t.Errorf("%q: expected an error, got %v", test.sequence, strokes)
+		case !test.error && err != nil:
+			t.Errorf("%q: unexpected error: %v", test.sequence, err)
+		case !test.error && !reflect.DeepEqual(strokes, test.expected):
+			t.Errorf("%q: got %v, expected %v", test.sequence, strokes, test.expected)
+		}
+	}
+}
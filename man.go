@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// printManPage writes the man page of mdrs in roff, from the definition of
+// the commands and of the config
+func printManPage(w io.Writer) {
+	var sb strings.Builder
+	line := func(format string, args ...interface{}) {
+		sb.WriteString(fmt.Sprintf(format, args...))
+		sb.WriteString("\n")
+	}
+	text := func(s string) {
+		line("%s", roffEscape(s))
+	}
+	tagged := func(tag string, description string) {
+		line(".TP")
+		line("%s", roffEscape(tag))
+		text(description)
+	}
+
+	line(".TH MDRS 1 \"\" %q \"User Commands\"", strings.TrimSpace("mdrs "+GitLastTag))
+
+	line(".SH NAME")
+	text("mdrs - markdown renderer and search for the terminal")
+
+	line(".SH SYNOPSIS")
+	line(".B mdrs")
+	text("[view] [options] [FILE]")
+	line(".br")
+	line(".B mdrs")
+	text("<command> [options] [arguments]")
+
+	line(".SH DESCRIPTION")
+	text("mdrs renders a markdown file in the terminal, to read and search it with the keyboard. " +
+		"Without a file, the markdown is read from stdin. " +
+		"When reopening a file, the reading position, the last search and the marks are restored.")
+
+	line(".SH COMMANDS")
+	commands := commandList()
+	for _, cmd := range commands {
+		usage := "mdrs " + cmd.name + " [options]"
+		if cmd.args != "" {
+			usage += " " + cmd.args
+		}
+		tagged(usage, cmd.summary+".")
+	}
+	tagged("mdrs help [COMMAND]", "Show the help of mdrs, or of a command.")
+
+	line(".SH OPTIONS")
+	text("The options can come before or after the file. " +
+		"The options setting the config take precedence over the config files and the environment.")
+	for _, cmd := range commands {
+		f := newCommandFlags(cmd)
+		line(".SS %s", roffEscape("mdrs "+cmd.name))
+		for _, option := range f.options {
+			tagged(option[0], option[1]+".")
+		}
+	}
+
+	line(".SH CONFIGURATION")
+	text("The config files are layered in this order, each one replacing the values set by the previous ones:")
+	for i, layer := range []string{
+		"the built-in defaults",
+		"the system-wide config files, mdrs/config.json in the directories of $XDG_CONFIG_DIRS (/etc/xdg by default)",
+		"the user config file, $XDG_CONFIG_HOME/mdrs/config.json (~/.config/mdrs/config.json by default)",
+		"the project config file, the closest .mdrs.json from the directory of the file",
+		"the config file given with --config or $MDRS_CONFIG, which replaces the user config file",
+		"the environment variables",
+		"the command line options",
+	} {
+		line(".IP %d. 4", i+1)
+		text(layer)
+	}
+	line(".PP")
+	text("The config files can also be written in YAML or TOML, with the .yaml, .yml or .toml extension instead of .json. " +
+		"They only need the settings that differ from the defaults. " +
+		"A setting set to null keeps its value, and an empty list of keys unbinds an action. " +
+		"The config files are reloaded when they change.")
+
+	line(".SH SETTINGS")
+	text("The settings of the config files, by path, with their default value.")
+	writeManSettings(tagged, reflect.ValueOf(*DefaultConfig()), "")
+
+	line(".SH KEYS")
+	text("Keys are single characters like \"k\", names like \"Up\", \"PageDown\", \"Enter\" or \"F1\", " +
+		"modifiers like \"C-f\" or \"M-x\", and sequences of several keys separated by spaces like \"g g\". " +
+		"\"<leader>\" stands for the leader key in a sequence. " +
+		"The default keys are:")
+	writeManKeys(tagged, reflect.ValueOf(DefaultConfig().Keybindings), "")
+
+	line(".SH ENVIRONMENT")
+	tagged("MDRS_CONFIG", "Another config file to use, like --config.")
+	tagged("MDRS_OPTS", "Settings given as options, like \"--theme dracula --width 80\".")
+	for _, option := range configOptions() {
+		if strings.HasPrefix(option.path, "colors.") {
+			continue
+		}
+		tagged(option.envName(), option.doc+", the "+option.path+" setting.")
+	}
+	tagged("MDRS_COLOR_<NAME>", "A color, like MDRS_COLOR_HEADING1=#ff5555, the colors.<name> setting.")
+	tagged("XDG_CONFIG_HOME, XDG_CONFIG_DIRS", "The directories of the user and system-wide config files.")
+	tagged("XDG_CACHE_HOME", "The directory of the renderings kept on disk with disk_cache, $XDG_CACHE_HOME/mdrs/render (~/.cache/mdrs/render by default).")
+	tagged("XDG_STATE_HOME", "The directory of the reading state, $XDG_STATE_HOME/mdrs/state.json (~/.local/state/mdrs/state.json by default).")
+
+	line(".SH THEMES")
+	names := make([]string, len(themes))
+	for i, t := range themes {
+		names[i] = t.name
+	}
+	text("The built-in themes of the theme setting: " + strings.Join(names, ", ") + ". " +
+		"The colors set explicitly take precedence over the theme.")
+
+	line(".SH SEE ALSO")
+	text("https://github.com/guttermonk/mdrs")
+
+	_, _ = io.WriteString(w, sb.String())
+}
+
+// writeManSettings lists the settings of a config struct with their default
+// value and description
+func writeManSettings(tagged func(string, string), v reflect.Value, prefix string) {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		path := joinPath(prefix, jsonName(field))
+
+		if v.Field(i).Kind() == reflect.Struct {
+			writeManSettings(tagged, v.Field(i), path)
+			continue
+		}
+
+		tagged(path+" = "+jsonValue(v.Field(i)), field.Tag.Get("doc")+".")
+	}
+}
+
+// writeManKeys lists the default keys of the actions, the ones of the other
+// views prefixed by the description of the view
+func writeManKeys(tagged func(string, string), v reflect.Value, view string) {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		switch value := v.Field(i).Interface().(type) {
+		case []string:
+			tagged(strings.Join(value, ", "), view+field.Tag.Get("doc")+".")
+		default:
+			if v.Field(i).Kind() == reflect.Struct {
+				writeManKeys(tagged, v.Field(i), field.Tag.Get("doc")+": ")
+			}
+		}
+	}
+}
+
+// roffEscape escapes text for roff: the backslashes, the dashes of the
+// options, and the dots and quotes starting a line
+func roffEscape(s string) string {
+	s = strings.NewReplacer(`\`, `\e`, "-", `\-`).Replace(s)
+	if strings.HasPrefix(s, ".") || strings.HasPrefix(s, "'") {
+		s = `\&` + s
+	}
+	return s
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/awesome-gocui/gocui"
+)
+
+const marksPopupView = "marksPopup"
+
+func (ui *ui) setMark(name rune) func(*gocui.Gui, *gocui.View) error {
+	return func(g *gocui.Gui, v *gocui.View) error {
+		ui.marks[name] = ui.positionAt(ui.YOffset)
+		ui.setMessage(g, fmt.Sprintf("Mark '%c' set", name))
+		return nil
+	}
+}
+
+func (ui *ui) jumpToMark(name rune) func(*gocui.Gui, *gocui.View) error {
+	return func(g *gocui.Gui, v *gocui.View) error {
+		pos, ok := ui.marks[name]
+		if !ok {
+			ui.setMessage(g, fmt.Sprintf("Mark '%c' not set", name))
+			return nil
+		}
+
+		_, maxY := g.Size()
+		ui.YOffset = max(min(ui.lineOf(pos), ui.lines-maxY+1), 0)
+		return nil
+	}
+}
+
+// marksPopup lists the marks of the document
+type marksPopup struct {
+	active bool
+	ui     *ui
+}
+
+func newMarksPopup(ui *ui) *marksPopup {
+	return &marksPopup{
+		active: false,
+		ui:     ui,
+	}
+}
+
+// jump returns the handler jumping to a mark from the popup
+func (mp *marksPopup) jump(name rune) func(*gocui.Gui, *gocui.View) error {
+	return func(g *gocui.Gui, v *gocui.View) error {
+		if err := mp.close(g, v); err != nil {
+			return err
+		}
+		return mp.ui.jumpToMark(name)(g, v)
+	}
+}
+
+func (mp *marksPopup) layout(g *gocui.Gui) error {
+	if !mp.active {
+		return nil
+	}
+
+	maxX, maxY := g.Size()
+
+	content := mp.buildContent()
+
+	// Calculate popup dimensions
+	width := 60
+	height := strings.Count(content, "\n") + 1
+
+	// Ensure popup fits on screen
+	if width > maxX-4 {
+		width = maxX - 4
+	}
+	if height > maxY-4 {
+		height = maxY - 4
+	}
+
+	// Center the popup
+	x0 := (maxX - width) / 2
+	y0 := (maxY - height) / 2
+
+	v, err := g.SetView(marksPopupView, x0, y0, x0+width, y0+height, 0)
+	if err != nil {
+		if !gocui.IsUnknownView(err) {
+			return err
+		}
+
+		v.Frame = true
+		v.Title = " Marks (Press a mark to jump, q to close) "
+		v.Autoscroll = false
+		v.Wrap = false
+	}
+
+	v.Clear()
+	fmt.Fprint(v, content)
+
+	// Set this view as current
+	if _, err := g.SetCurrentView(marksPopupView); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (mp *marksPopup) buildContent() string {
+	var sb strings.Builder
+
+	names := make([]rune, 0, len(mp.ui.marks))
+	for name := range mp.ui.marks {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+
+	if len(names) == 0 {
+		sb.WriteString("  No marks set\n")
+		return sb.String()
+	}
+
+	for _, name := range names {
+		pos := mp.ui.marks[name]
+		section := ""
+		if pos.Section != "" || pos.Title != "" {
+			section = fmt.Sprintf("%s %s", pos.Section, pos.Title)
+		}
+		sb.WriteString(fmt.Sprintf("  %c  %6d  %s\n", name, mp.ui.lineOf(pos)+1, section))
+	}
+
+	return sb.String()
+}
+
+func (mp *marksPopup) show() {
+	mp.active = true
+}
+
+func (mp *marksPopup) close(g *gocui.Gui, v *gocui.View) error {
+	mp.active = false
+	if err := g.DeleteView(marksPopupView); err != nil {
+		return err
+	}
+	// Return focus to the main render view
+	if _, err := g.SetCurrentView(renderView); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (mp *marksPopup) isActive() bool {
+	return mp.active
+}
+
+func (ui *ui) showMarks(g *gocui.Gui, v *gocui.View) error {
+	ui.marksPopup.show()
+	return nil
+}
@@ -1,79 +1,146 @@
 package main
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path"
+	"path/filepath"
 	"strings"
+	"time"
 
-	"github.com/MichaelMure/go-term-markdown"
 	"github.com/awesome-gocui/gocui"
-	"github.com/mattn/go-isatty"
 	"github.com/pkg/errors"
 )
 
 const padding = 4
 
 func main() {
-	if len(os.Args) >= 2 && (os.Args[1] == "version" || os.Args[1] == "--version") {
-		printVersion()
-		return
+	err := runCommand(os.Args[1:])
+	if err == errUsage {
+		os.Exit(2)
 	}
+	if err != nil {
+		exitError(err)
+	}
+}
 
-	if len(os.Args) >= 2 && (os.Args[1] == "--init-config") {
-		initConfig()
-		return
+// printConfigPaths prints the locations of the config files
+func printConfigPaths(w io.Writer) {
+	_, _ = fmt.Fprintf(w, "Config file location: %s\n", getConfigPath())
+	for _, path := range getSystemConfigPaths() {
+		_, _ = fmt.Fprintf(w, "System-wide config file: %s\n", path)
+	}
+	if path := findProjectConfig("."); path != "" {
+		_, _ = fmt.Fprintf(w, "Project config file: %s\n", path)
 	}
+}
 
-	if len(os.Args) >= 2 && (os.Args[1] == "--config-path") {
-		fmt.Printf("Config file location: %s\n", getConfigPath())
-		return
+// readDocument reads the markdown file given as argument, or stdin, which
+// may be compressed, and converts it to UTF-8 from the given encoding. It
+// also returns the original name of the document: the
+// name of the file without its compression extension, or the name recorded
+// in the compressed stdin if any.
+func readDocument(files []string, encoding string) ([]byte, string, error) {
+	var in io.Reader = os.Stdin
+	name := ""
+	if len(files) > 0 {
+		f, err := os.Open(files[0])
+		if err != nil {
+			return nil, "", errors.Wrap(err, "error while reading file")
+		}
+		defer f.Close()
+		in = f
+		name = uncompressedName(path.Base(files[0]))
 	}
 
-	var content []byte
+	r, originalName, err := decompressReader(in)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "error while decompressing")
+	}
+	if name == "" {
+		name = originalName
+	}
 
-	switch len(os.Args) {
-	case 1:
-		if isatty.IsTerminal(os.Stdin.Fd()) {
-			exitError(fmt.Errorf("usage: %s <file.md>", os.Args[0]))
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		if len(files) == 0 {
+			return nil, "", errors.Wrap(err, "error while reading STDIN")
 		}
-		data, err := ioutil.ReadAll(os.Stdin)
+		return nil, "", errors.Wrap(err, "error while reading file")
+	}
+
+	text, err := decodeText(data, encoding)
+	if err != nil {
+		return nil, "", err
+	}
+	return []byte(text), name, nil
+}
+
+// viewFile displays the markdown file given as argument, or stdin, restoring
+// the last reading position of the file if asked
+func viewFile(files []string, encoding string, restore bool) error {
+	var content []byte
+	var fileName, filePath string
+	var err error
+
+	// stdin is streamed once the ui is started
+	if len(files) == 1 {
+		content, fileName, err = readDocument(files, encoding)
 		if err != nil {
-			exitError(errors.Wrap(err, "error while reading STDIN"))
+			return err
 		}
-		content = data
-	case 2:
-		data, err := ioutil.ReadFile(os.Args[1])
+
+		filePath, err = filepath.Abs(files[0])
 		if err != nil {
-			exitError(errors.Wrap(err, "error while reading file"))
+			return err
 		}
-		err = os.Chdir(path.Dir(os.Args[1]))
+		err = os.Chdir(path.Dir(files[0]))
 		if err != nil {
-			exitError(err)
+			return err
 		}
-		content = data
-
-	default:
-		exitError(fmt.Errorf("only one file is supported"))
 	}
 
 	g, err := gocui.NewGui(gocui.OutputNormal, false)
 	if err != nil {
-		exitError(errors.Wrap(err, "error starting the interactive UI"))
+		return errors.Wrap(err, "error starting the interactive UI")
 	}
 	defer g.Close()
 
 	ui, err := newUi(g)
 	if err != nil {
-		exitError(err)
+		return err
 	}
 
-	ui.setContent(content)
+	ui.fileName = fileName
+	ui.filePath = filePath
+	if len(files) == 1 {
+		ui.setContent(content)
+	} else {
+		ui.streamDocument(g, os.Stdin, encoding)
+	}
+
+	// The state is a convenience, a broken state file is not worth failing for
+	_ = ui.loadState(restore)
 
 	if err := g.MainLoop(); err != nil && err != gocui.ErrQuit {
-		exitError(err)
+		return err
+	}
+
+	_ = ui.saveState()
+	return nil
+}
+
+// configDir returns the directory of the file given as argument, where the
+// project config is searched from, or the current directory
+func configDir(args []string) string {
+	if len(args) == 0 {
+		return "."
 	}
+	return filepath.Dir(args[0])
 }
 
 func exitError(err error) {
@@ -81,24 +148,63 @@ func exitError(err error) {
 	os.Exit(1)
 }
 
-func initConfig() {
-	config := DefaultConfig()
+// initConfig creates the config file in the given format: an empty JSON
+// object, or every setting commented out for the formats with comments
+func initConfig(formatName string) {
 	configPath := getConfigPath()
-	
+	format := configFormatOf(configPath)
+
+	if formatName != "" {
+		var err error
+		format, err = configFormatByName(formatName)
+		if err != nil {
+			exitError(err)
+		}
+	}
+
+	if configPath == "" {
+		exitError(fmt.Errorf("no config location: the home directory is unknown"))
+	}
+
+	// The user config file may exist in another format
+	existing := configPath
+	if !explicitConfigPath() {
+		existing = findConfigFile(getUserConfigDir(), "config")
+		configPath = filepath.Join(getUserConfigDir(), "config"+format.extensions[0])
+	}
+
 	// Check if config already exists
-	if _, err := os.Stat(configPath); err == nil {
-		fmt.Printf("Config file already exists at: %s\n", configPath)
+	if _, err := os.Stat(existing); existing != "" && err == nil {
+		fmt.Printf("Config file already exists at: %s\n", existing)
 		fmt.Println("To regenerate, please delete the existing file first.")
 		return
 	}
-	
-	// Save the default config
-	if err := config.Save(); err != nil {
+
+	if format.template != nil {
+		if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
+			exitError(fmt.Errorf("failed to create config directory: %w", err))
+		}
+		if err := ioutil.WriteFile(configPath, []byte(format.template(DefaultConfig())), 0644); err != nil {
+			exitError(fmt.Errorf("failed to create config file: %w", err))
+		}
+		fmt.Printf("Created config file at: %s\n", configPath)
+		fmt.Println("Every setting is commented out, uncomment the ones to change.")
+		return
+	}
+
+	// Save the default config, which has no overrides yet
+	if err := DefaultConfig().Save(configPath); err != nil {
 		exitError(fmt.Errorf("failed to create config file: %w", err))
 	}
-	
-	fmt.Printf("Created default config file at: %s\n", configPath)
-	fmt.Println("You can now edit this file to customize colors.")
+
+	fmt.Printf("Created config file at: %s\n", configPath)
+	fmt.Println("It only needs the settings that differ from the defaults, for example:")
+	fmt.Println()
+	fmt.Println(`  {`)
+	fmt.Println(`    "max_width": 100,`)
+	fmt.Println(`    "colors": { "heading1": "#ff8700" },`)
+	fmt.Println(`    "keybindings": { "quit": ["q", "Q"] }`)
+	fmt.Println(`  }`)
 	fmt.Println("\nExample color values:")
 	fmt.Println("  \"#ff0000\" - Red")
 	fmt.Println("  \"#00ff00\" - Green")
@@ -114,117 +220,226 @@ const statusView = "status"
 
 type ui struct {
 	keybindings []keybinding
+	keys        *keyDispatcher
 
 	raw string
+	// hash of raw, to find its renderings in the cache
+	rawHash [sha256.Size]byte
+	// destinations of the images of raw, also part of the cache key
+	images []string
+	// name of the displayed file, empty for stdin
+	fileName string
+	// absolute path of the displayed file, empty for stdin
+	filePath string
+	// remembered state of the file, to restore after the first render
+	restore *fileState
 	// current width of the view
 	width   int
 	XOffset int
 	YOffset int
 
+	// maximum width of the text column, 0 for the full width
+	maxWidth int
+	// left margin of the text column when centered
+	margin int
+
 	// number of lines in the rendered markdown
 	lines int
+	// headings of the document, located in the rendered markdown
+	headings []heading
+
+	showStatus bool
+	// whether the document is still being read
+	loading bool
 
 	// search state
 	search          *SearchState
 	renderedContent []byte
-	searchActive    bool
-	
+	// renderedContent split in lines, to write only the visible ones
+	renderedLines [][]byte
+	// renders the document in the background
+	renderer     *renderWorker
+	searchActive bool
+
 	// configuration
-	config          *Config
-	
+	config *Config
+
 	// help popup
-	help            *helpPopup
+	help *helpPopup
+
+	// marks of the document, and the popup listing them
+	marks      map[rune]position
+	marksPopup *marksPopup
+
+	// transient message displayed in the status line
+	message   string
+	messageID int
 }
 
 func newUi(g *gocui.Gui) (*ui, error) {
-	config, err := LoadConfig()
-	if err != nil {
-		// Use default config if loading fails
-		config = DefaultConfig()
-	}
-	
+	// The current directory is the one of the file, if any
+	// The config files that can't be loaded are skipped, the problem is
+	// reported in the status line below
+	config, err := LoadConfig(".")
+
 	result := &ui{
-		width:  -1,
-		search: NewSearchState(config),
-		config: config,
-		help:   newHelpPopup(config),
+		width:      -1,
+		maxWidth:   config.MaxWidth,
+		showStatus: !config.HideStatus,
+		search:     NewSearchState(config),
+		config:     config,
+		help:       newHelpPopup(config),
+		marks:      make(map[rune]position),
 	}
+	result.marksPopup = newMarksPopup(result)
+	result.renderer = newRenderWorker(g, result)
 
 	g.SetManagerFunc(result.layout)
 	g.Cursor = false
 	g.InputEsc = true
 
-	// Build keybindings from config
-	result.keybindings = []keybinding{}
-	
-	// Navigation keybindings
-	result.keybindings = append(result.keybindings, createKeybindingsFromStrings(renderView, result.config.Keybindings.ScrollUp, result.up)...)
-	result.keybindings = append(result.keybindings, createKeybindingsFromStrings(renderView, result.config.Keybindings.ScrollDown, result.down)...)
-	result.keybindings = append(result.keybindings, createKeybindingsFromStrings(renderView, result.config.Keybindings.ScrollLeft, result.left)...)
-	result.keybindings = append(result.keybindings, createKeybindingsFromStrings(renderView, result.config.Keybindings.ScrollRight, result.right)...)
-	result.keybindings = append(result.keybindings, createKeybindingsFromStrings(renderView, result.config.Keybindings.PageUp, result.pageUp)...)
-	result.keybindings = append(result.keybindings, createKeybindingsFromStrings(renderView, result.config.Keybindings.PageDown, result.pageDown)...)
-	result.keybindings = append(result.keybindings, createKeybindingsFromStrings(renderView, result.config.Keybindings.GoToTop, result.goToTop)...)
-	result.keybindings = append(result.keybindings, createKeybindingsFromStrings(renderView, result.config.Keybindings.GoToBottom, result.goToBottom)...)
-	
-	// Search keybindings
-	result.keybindings = append(result.keybindings, createKeybindingsFromStrings(renderView, result.config.Keybindings.StartSearch, result.startSearch)...)
-	result.keybindings = append(result.keybindings, createKeybindingsFromStrings(renderView, result.config.Keybindings.NextMatch, result.nextMatch)...)
-	result.keybindings = append(result.keybindings, createKeybindingsFromStrings(renderView, result.config.Keybindings.PrevMatch, result.prevMatch)...)
-	result.keybindings = append(result.keybindings, createKeybindingsFromStrings(renderView, result.config.Keybindings.ClearSearch, result.clearSearch)...)
-	
-	// General keybindings
-	result.keybindings = append(result.keybindings, createKeybindingsFromStrings(renderView, result.config.Keybindings.Quit, result.quit)...)
-	result.keybindings = append(result.keybindings, createKeybindingsFromStrings("", result.config.Keybindings.Quit, result.quit)...)  // Global quit
-	result.keybindings = append(result.keybindings, createKeybindingsFromStrings(renderView, result.config.Keybindings.ShowHelp, result.showHelp)...)
-	
-	// Search view specific keybindings (always fixed)
-	result.keybindings = append(result.keybindings, keybinding{searchView, gocui.KeyEnter, gocui.ModNone, result.executeSearch})
-	result.keybindings = append(result.keybindings, keybinding{searchView, gocui.KeyEsc, gocui.ModNone, result.cancelSearch})
-	result.keybindings = append(result.keybindings, keybinding{searchView, gocui.KeyCtrlC, gocui.ModNone, result.cancelSearch})
-	result.keybindings = append(result.keybindings, keybinding{searchView, gocui.KeyCtrlG, gocui.ModNone, result.cancelSearch})
-	
-	// Register help popup keybindings
-	if err := result.help.keybindings(g); err != nil {
+	// The keys that can't be parsed are reported below with the others
+	keybindings, kerr := result.buildKeybindings()
+	if err == nil {
+		err = kerr
+	}
+	if err := result.setKeybindings(g, keybindings); err != nil {
 		return nil, err
 	}
 
-	for _, kb := range result.keybindings {
-		err := kb.Register(g)
-		if err != nil {
-			return nil, err
-		}
+	warning := configWarning(".")
+	if warning == "" && err != nil {
+		warning = fmt.Sprintf("Config: %v", err)
 	}
+	if warning != "" {
+		result.showMessage(g, warning, warningDuration)
+	}
+
+	result.watchConfig(g)
 
 	return result, nil
 }
 
+// setKeybindings replaces the keybindings registered with gocui
+func (ui *ui) setKeybindings(g *gocui.Gui, keybindings []keybinding) error {
+	if ui.keys != nil {
+		ui.keys.unregister(g)
+	}
+
+	// Keys assigned to several actions go to a single one, the conflicts
+	// are listed in the help popup
+	conflicts := findConflicts(keybindings, ui.config.Keybindings.isCustom)
+	ui.keybindings = resolveConflicts(keybindings, conflicts)
+	ui.help.conflicts = conflicts
+
+	timeout := time.Duration(ui.config.Keybindings.KeyTimeout) * time.Millisecond
+	ui.keys = newKeyDispatcher(timeout, renderView)
+	return ui.keys.register(g, ui.keybindings)
+}
+
+// buildKeybindings creates the keybindings from the config. All the keys that
+// can't be parsed are reported in the returned error, along with the
+// keybindings that could be created.
+func (ui *ui) buildKeybindings() ([]keybinding, error) {
+	keys := ui.config.Keybindings
+	b := &keybindingBuilder{leader: keys.Leader}
+
+	// Navigation keybindings
+	b.add(renderView, "scroll_up", keys.ScrollUp, ui.up)
+	b.add(renderView, "scroll_down", keys.ScrollDown, ui.down)
+	b.add(renderView, "scroll_left", keys.ScrollLeft, ui.left)
+	b.add(renderView, "scroll_right", keys.ScrollRight, ui.right)
+	b.add(renderView, "page_up", keys.PageUp, ui.pageUp)
+	b.add(renderView, "page_down", keys.PageDown, ui.pageDown)
+	b.add(renderView, "go_to_top", keys.GoToTop, ui.goToTop)
+	b.add(renderView, "go_to_bottom", keys.GoToBottom, ui.goToBottom)
+	b.add(renderView, "cycle_width", keys.CycleWidth, ui.cycleWidth)
+
+	// Marks keybindings
+	b.addNamed(renderView, "set_mark", keys.SetMark, ui.setMark)
+	b.addNamed(renderView, "jump_to_mark", keys.JumpToMark, ui.jumpToMark)
+	b.add(renderView, "show_marks", keys.ShowMarks, ui.showMarks)
+
+	// Search keybindings
+	b.add(renderView, "start_search", keys.StartSearch, ui.startSearch)
+	b.add(renderView, "next_match", keys.NextMatch, ui.nextMatch)
+	b.add(renderView, "prev_match", keys.PrevMatch, ui.prevMatch)
+	b.add(renderView, "clear_search", keys.ClearSearch, ui.clearSearch)
+
+	// General keybindings
+	b.add(renderView, "quit", keys.Quit, ui.quit)
+	b.add(renderView, "show_help", keys.ShowHelp, ui.showHelp)
+	b.add(renderView, "toggle_status", keys.ToggleStatus, ui.toggleStatus)
+
+	// Global quit, its errors are already reported above
+	global := &keybindingBuilder{leader: keys.Leader}
+	global.add("", "quit", keys.Quit, ui.quit)
+	b.bindings = append(b.bindings, global.bindings...)
+
+	// Search view keybindings
+	b.add(searchView, "search.execute", keys.Search.Execute, ui.executeSearch)
+	b.add(searchView, "search.cancel", keys.Search.Cancel, ui.cancelSearch)
+
+	// Popup keybindings
+	b.add(helpPopupView, "help.close", keys.Help.Close, ui.help.close)
+	b.add(helpPopupView, "help.scroll_up", keys.Help.ScrollUp, ui.help.scrollLines(-1))
+	b.add(helpPopupView, "help.scroll_down", keys.Help.ScrollDown, ui.help.scrollLines(1))
+	b.add(helpPopupView, "help.page_up", keys.Help.PageUp, ui.help.scrollPages(-1))
+	b.add(helpPopupView, "help.page_down", keys.Help.PageDown, ui.help.scrollPages(1))
+	b.add(marksPopupView, "marks.close", keys.Marks.Close, ui.marksPopup.close)
+
+	// Jump directly to a mark from the marks popup. These keys are fixed,
+	// so they give way to the ones above, like "q" to close.
+	for name := 'a'; name <= 'z'; name++ {
+		b.bindings = append(b.bindings, singleKey(marksPopupView, name, ui.marksPopup.jump(name)))
+	}
+
+	return b.bindings, b.err()
+}
+
 func (ui *ui) setContent(content []byte) {
 	ui.raw = string(content)
+	ui.rawHash = sha256.Sum256(content)
+	ui.images = documentImages(ui.raw)
 	ui.width = -1
 }
 
 func (ui *ui) layout(g *gocui.Gui) error {
 	maxX, maxY := g.Size()
-	
+
 	// Handle help popup first (it should be on top)
 	if err := ui.help.layout(g); err != nil {
 		return err
 	}
-	
+
 	// If help is active, don't update other views
 	if ui.help.isActive() {
 		return nil
 	}
 
+	if err := ui.marksPopup.layout(g); err != nil {
+		return err
+	}
+	if ui.marksPopup.isActive() {
+		return nil
+	}
+
 	// Status bar at the bottom
 	statusY := maxY - 1
 	if ui.searchActive {
 		statusY = maxY - 3
 	}
 
-	// Main render view
-	v, err := g.SetView(renderView, ui.XOffset, -ui.YOffset, maxX, statusY, 0)
+	// Keep the text column on screen when the layout changes
+	_, ui.margin = ui.columnLayout(maxX)
+	ui.XOffset = max(ui.XOffset, -ui.margin)
+	if ui.margin > 0 {
+		ui.XOffset = min(ui.XOffset, ui.margin)
+	}
+
+	// Main render view, fixed on the screen, only the visible lines of the
+	// document are written to it
+	v, err := g.SetView(renderView, ui.margin+ui.XOffset, -1, maxX, statusY, 0)
 	if err != nil {
 		if !gocui.IsUnknownView(err) {
 			return err
@@ -236,36 +451,16 @@ func (ui *ui) layout(g *gocui.Gui) error {
 
 	if len(ui.raw) > 0 && ui.width != maxX {
 		ui.width = maxX
-		v.Clear()
-		ui.renderedContent = ui.render(g)
-		
-		// Apply search highlighting if search is active
-		if ui.search.term != "" {
-			_, _ = v.Write(ui.search.HighlightContent(ui.renderedContent))
-		} else {
-			_, _ = v.Write(ui.renderedContent)
-		}
-	} else if ui.search.term != "" {
-		// Update highlighting even if width hasn't changed
-		v.Clear()
-		_, _ = v.Write(ui.search.HighlightContent(ui.renderedContent))
+		ui.requestRender(g, maxX)
 	}
 
-	// Status bar
-	if ui.search.term != "" || ui.searchActive {
-		sv, err := g.SetView(statusView, 0, statusY, maxX-1, statusY+2, 0)
-		if err != nil {
-			if !gocui.IsUnknownView(err) {
-				return err
-			}
-			sv.Frame = false
-			sv.Wrap = false
-		}
-		sv.Clear()
-		
-		statusText := ui.search.GetStatusText()
-		if statusText != "" {
-			fmt.Fprintf(sv, " %s", statusText)
+	v.Clear()
+	ui.writeVisibleLines(v, statusY)
+
+	// Status bar, always shown while loading, searching or for a message
+	if ui.showStatus || ui.loading || ui.search.term != "" || ui.searchActive || ui.message != "" {
+		if err := ui.layoutStatus(g, statusY); err != nil {
+			return err
 		}
 	} else {
 		g.DeleteView(statusView)
@@ -290,7 +485,7 @@ func (ui *ui) layout(g *gocui.Gui) error {
 		if err != nil {
 			return err
 		}
-		
+
 		// Set cursor to end of search term
 		sv.SetCursor(len(sv.Buffer()), 0)
 	} else {
@@ -305,20 +500,40 @@ func (ui *ui) layout(g *gocui.Gui) error {
 	return nil
 }
 
-func (ui *ui) render(g *gocui.Gui) []byte {
-	maxX, _ := g.Size()
+// columnLayout returns the line width to render at and the left margin of
+// the text column for a terminal of the given width.
+func (ui *ui) columnLayout(maxX int) (lineWidth int, margin int) {
+	lineWidth = maxX - 1 - padding
+	if ui.maxWidth <= 0 || ui.maxWidth+padding >= lineWidth {
+		return lineWidth, 0
+	}
 
-	// Get options from config, plus required options
-	opts := ui.config.GetMarkdownOptions()
+	lineWidth = ui.maxWidth + padding
+	if ui.config.Center {
+		// balance the blank space on both sides of the text
+		margin = (maxX - lineWidth - padding) / 2
+	}
+	return lineWidth, margin
+}
 
-	rendered := markdown.Render(ui.raw, maxX-1-padding, padding, opts...)
-	ui.lines = 0
-	for _, b := range rendered {
-		if b == '\n' {
-			ui.lines++
+// topLine is the line of the document on the first row of the render view:
+// the line above YOffset, blank at the top of the document
+func (ui *ui) topLine() int {
+	return ui.YOffset - 1
+}
+
+// writeVisibleLines writes the lines of the document on screen to the
+// render view, highlighting the search matches
+func (ui *ui) writeVisibleLines(v *gocui.View, height int) {
+	var buf bytes.Buffer
+	for row := 0; row < height; row++ {
+		line := ui.topLine() + row
+		if line >= 0 && line < ui.lines {
+			buf.Write(ui.search.HighlightLine(line, ui.renderedLines[line]))
 		}
+		buf.WriteByte('\n')
 	}
-	return rendered
+	_, _ = v.Write(buf.Bytes())
 }
 
 func (ui *ui) startSearch(g *gocui.Gui, v *gocui.View) error {
@@ -334,7 +549,7 @@ func (ui *ui) executeSearch(g *gocui.Gui, v *gocui.View) error {
 
 	// Perform the search
 	ui.search.SetTerm(searchText, string(ui.renderedContent))
-	
+
 	// If we found matches, scroll to the first one
 	if match, ok := ui.search.GetCurrentMatch(); ok {
 		ui.scrollToLine(g, match.lineNumber)
@@ -343,10 +558,7 @@ func (ui *ui) executeSearch(g *gocui.Gui, v *gocui.View) error {
 	ui.searchActive = false
 	v.Clear()
 	v.SetCursor(0, 0)
-	
-	// Force a re-render to show highlights
-	ui.width = -1
-	
+
 	return nil
 }
 
@@ -355,20 +567,14 @@ func (ui *ui) cancelSearch(g *gocui.Gui, v *gocui.View) error {
 	ui.search.Clear()
 	v.Clear()
 	v.SetCursor(0, 0)
-	
-	// Force a re-render to clear highlights
-	ui.width = -1
-	
+
 	return nil
 }
 
 func (ui *ui) clearSearch(g *gocui.Gui, v *gocui.View) error {
 	ui.searchActive = false
 	ui.search.Clear()
-	
-	// Force a re-render to clear highlights
-	ui.width = -1
-	
+
 	return nil
 }
 
@@ -376,13 +582,15 @@ func (ui *ui) nextMatch(g *gocui.Gui, v *gocui.View) error {
 	if ui.search.term == "" {
 		return nil
 	}
-	
+
+	// going around all the matches changes nothing
+	for i := 0; i < (ui.keys.repeat()-1)%max(ui.search.GetMatchCount(), 1); i++ {
+		ui.search.NextMatch()
+	}
 	if match, ok := ui.search.NextMatch(); ok {
 		ui.scrollToLine(g, match.lineNumber)
-		// Force re-render to update highlight
-		ui.width = -1
 	}
-	
+
 	return nil
 }
 
@@ -390,22 +598,24 @@ func (ui *ui) prevMatch(g *gocui.Gui, v *gocui.View) error {
 	if ui.search.term == "" {
 		return nil
 	}
-	
+
+	// going around all the matches changes nothing
+	for i := 0; i < (ui.keys.repeat()-1)%max(ui.search.GetMatchCount(), 1); i++ {
+		ui.search.PrevMatch()
+	}
 	if match, ok := ui.search.PrevMatch(); ok {
 		ui.scrollToLine(g, match.lineNumber)
-		// Force re-render to update highlight
-		ui.width = -1
 	}
-	
+
 	return nil
 }
 
 func (ui *ui) scrollToLine(g *gocui.Gui, lineNumber int) {
 	_, maxY := g.Size()
-	
+
 	// Try to center the match on screen
 	targetOffset := lineNumber - maxY/2
-	
+
 	// Clamp to valid range
 	ui.YOffset = max(0, min(targetOffset, ui.lines-maxY+1))
 }
@@ -415,57 +625,102 @@ func (ui *ui) quit(g *gocui.Gui, v *gocui.View) error {
 }
 
 func (ui *ui) up(g *gocui.Gui, v *gocui.View) error {
-	ui.YOffset -= 1
+	ui.YOffset -= ui.keys.repeat()
 	ui.YOffset = max(ui.YOffset, 0)
 	return nil
 }
 
 func (ui *ui) down(g *gocui.Gui, v *gocui.View) error {
 	_, maxY := g.Size()
-	ui.YOffset += 1
+	ui.YOffset += ui.keys.repeat()
 	ui.YOffset = min(ui.YOffset, ui.lines-maxY+1)
 	ui.YOffset = max(ui.YOffset, 0)
 	return nil
 }
 
 func (ui *ui) left(g *gocui.Gui, v *gocui.View) error {
-	ui.XOffset -= 1
-	ui.XOffset = max(ui.XOffset, 0)
+	ui.XOffset -= ui.keys.repeat()
+	ui.XOffset = max(ui.XOffset, -ui.margin)
 	return nil
 }
 
 func (ui *ui) right(g *gocui.Gui, v *gocui.View) error {
-	ui.XOffset += 1
+	ui.XOffset += ui.keys.repeat()
+	// a centered column can only move within its margin
+	if ui.margin > 0 {
+		ui.XOffset = min(ui.XOffset, ui.margin)
+	}
 	return nil
 }
 
 func (ui *ui) pageUp(g *gocui.Gui, v *gocui.View) error {
 	_, maxY := g.Size()
-	ui.YOffset -= maxY / 2
+	ui.YOffset -= maxY / 2 * ui.keys.repeat()
 	ui.YOffset = max(ui.YOffset, 0)
 	return nil
 }
 
 func (ui *ui) pageDown(g *gocui.Gui, v *gocui.View) error {
 	_, maxY := g.Size()
-	ui.YOffset += maxY / 2
+	ui.YOffset += maxY / 2 * ui.keys.repeat()
 	ui.YOffset = min(ui.YOffset, ui.lines-maxY+1)
 	ui.YOffset = max(ui.YOffset, 0)
 	return nil
 }
 
 func (ui *ui) goToTop(g *gocui.Gui, v *gocui.View) error {
+	// with a count, go to that line instead
+	if ui.keys.count > 0 {
+		return ui.goToLine(g, ui.keys.count)
+	}
 	ui.YOffset = 0
 	return nil
 }
 
 func (ui *ui) goToBottom(g *gocui.Gui, v *gocui.View) error {
+	// with a count, go to that line instead
+	if ui.keys.count > 0 {
+		return ui.goToLine(g, ui.keys.count)
+	}
 	_, maxY := g.Size()
 	ui.YOffset = ui.lines - maxY + 1
 	ui.YOffset = max(ui.YOffset, 0)
 	return nil
 }
 
+func (ui *ui) goToLine(g *gocui.Gui, line int) error {
+	_, maxY := g.Size()
+	ui.YOffset = line - 1
+	ui.YOffset = min(ui.YOffset, ui.lines-maxY+1)
+	ui.YOffset = max(ui.YOffset, 0)
+	return nil
+}
+
+// widthSteps are the text column widths cycled through by cycleWidth,
+// 0 being the full terminal width.
+var widthSteps = []int{80, 100, 120, 0}
+
+func (ui *ui) cycleWidth(g *gocui.Gui, v *gocui.View) error {
+	next := widthSteps[0]
+	if ui.maxWidth > 0 {
+		next = 0
+		for _, w := range widthSteps {
+			if w > ui.maxWidth {
+				next = w
+				break
+			}
+		}
+	}
+
+	ui.maxWidth = next
+	ui.XOffset = 0
+
+	// Force a re-render at the new width
+	ui.width = -1
+
+	return nil
+}
+
 func (ui *ui) showHelp(g *gocui.Gui, v *gocui.View) error {
 	ui.help.show()
 	return nil
@@ -483,4 +738,4 @@ func max(a, b int) int {
 		return a
 	}
 	return b
-}
\ No newline at end of file
+}
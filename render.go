@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"sync/atomic"
+	"time"
+
+	"github.com/MichaelMure/go-term-markdown"
+	"github.com/awesome-gocui/gocui"
+	"github.com/fatih/color"
+)
+
+// renderRequest asks for the document to be rendered for a width
+type renderRequest struct {
+	id        int64
+	key       renderKey
+	raw       string
+	lineWidth int
+	opts      []markdown.Options
+	// whether to keep the rendering on disk if slow
+	diskCache bool
+}
+
+// rendering is the document rendered for a width
+type rendering struct {
+	content  []byte
+	lines    [][]byte
+	count    int
+	headings []heading
+}
+
+// renderWorker renders the document in the background, so that a big
+// document doesn't freeze the ui on resize. Only the latest request matters:
+// the pending ones are dropped when a new one comes, and the result of a
+// render overtaken by a new request is thrown away.
+type renderWorker struct {
+	latest   int64
+	requests chan renderRequest
+	cache    *renderCache
+}
+
+func newRenderWorker(g *gocui.Gui, ui *ui) *renderWorker {
+	w := &renderWorker{
+		requests: make(chan renderRequest, 1),
+		cache:    newRenderCache(),
+	}
+
+	go func() {
+		for req := range w.requests {
+			if !w.isLatest(req.id) {
+				continue
+			}
+			r := w.render(req)
+			if !w.isLatest(req.id) {
+				continue
+			}
+			g.Update(func(g *gocui.Gui) error {
+				// a request may have come while waiting for the update
+				if w.isLatest(req.id) {
+					ui.setRendering(g, r)
+				}
+				return nil
+			})
+		}
+	}()
+
+	return w
+}
+
+// request asks for a render, replacing the pending request. It is only
+// called from the ui goroutine.
+func (w *renderWorker) request(req renderRequest) {
+	req.id = atomic.AddInt64(&w.latest, 1)
+	select {
+	case <-w.requests:
+	default:
+	}
+	w.requests <- req
+}
+
+// cached returns the rendering of a request if in memory, cancelling the
+// pending request. It is only called from the ui goroutine.
+func (w *renderWorker) cached(req renderRequest) (*rendering, bool) {
+	r, ok := w.cache.get(req.key)
+	if ok {
+		atomic.AddInt64(&w.latest, 1)
+	}
+	return r, ok
+}
+
+func (w *renderWorker) isLatest(id int64) bool {
+	return atomic.LoadInt64(&w.latest) == id
+}
+
+// render renders a request, or reads it from the disk cache, and keeps the
+// result in the cache
+func (w *renderWorker) render(req renderRequest) *rendering {
+	if req.diskCache {
+		if r, err := loadDiskRendering(req.key); err == nil {
+			w.cache.add(req.key, r)
+			return r
+		}
+	}
+
+	start := time.Now()
+	r := renderDocument(req)
+	w.cache.add(req.key, r)
+
+	// the disk cache is a convenience, failing to write it is not an error
+	if req.diskCache && time.Since(start) >= diskCacheMinDuration {
+		_ = saveDiskRendering(req.key, r)
+	}
+	return r
+}
+
+// renderDocument renders the markdown and locates its headings
+func renderDocument(req renderRequest) *rendering {
+	content := markdown.Render(req.raw, req.lineWidth, padding, req.opts...)
+	return newRendering(content, locateHeadings(parseHeadings(req.raw), content))
+}
+
+func newRendering(content []byte, headings []heading) *rendering {
+	return &rendering{
+		content:  content,
+		lines:    bytes.Split(content, []byte("\n")),
+		count:    bytes.Count(content, []byte("\n")),
+		headings: headings,
+	}
+}
+
+// requestRender starts rendering the document for the width of the screen,
+// the current rendering staying on screen until the new one is ready. A
+// cached rendering is used right away.
+func (ui *ui) requestRender(g *gocui.Gui, maxX int) {
+	lineWidth, _ := ui.columnLayout(maxX)
+	images, remote := imagesKey(ui.images)
+	req := renderRequest{
+		key: renderKey{
+			hash:    ui.rawHash,
+			width:   lineWidth,
+			options: ui.config.MarkdownOptionsKey(),
+			noColor: color.NoColor,
+			images:  images,
+		},
+		raw:       ui.raw,
+		lineWidth: lineWidth,
+		opts:      ui.config.GetMarkdownOptions(),
+		diskCache: ui.config.DiskCache && !remote,
+	}
+
+	if r, ok := ui.renderer.cached(req); ok {
+		ui.setRendering(g, r)
+		return
+	}
+	ui.renderer.request(req)
+}
+
+// setRendering swaps in a new rendering of the document
+func (ui *ui) setRendering(g *gocui.Gui, r *rendering) {
+	_, maxY := g.Size()
+
+	ui.renderedContent = r.content
+	ui.renderedLines = r.lines
+	ui.lines = r.count
+	ui.headings = r.headings
+	ui.YOffset = min(ui.YOffset, max(ui.lines-maxY+1, 0))
+	if ui.search.term != "" {
+		ui.search.Refresh(string(ui.renderedContent))
+	}
+
+	if ui.restore != nil {
+		ui.restoreState(g)
+	}
+}
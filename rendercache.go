@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// renderCacheSize is the number of renderings kept in memory
+const renderCacheSize = 8
+
+// diskCacheSize is the number of renderings kept on disk
+const diskCacheSize = 16
+
+// diskCacheMinDuration is how long a rendering must take to be kept on disk,
+// the faster ones are not worth the space
+const diskCacheMinDuration = 500 * time.Millisecond
+
+// renderKey identifies a rendering: the same document rendered for the same
+// width with the same settings and the same images gives the same result. The
+// colors are not part of it, they are applied when displaying.
+type renderKey struct {
+	hash    [sha256.Size]byte
+	width   int
+	options string
+	noColor bool
+	images  string
+}
+
+// imagePattern finds the destinations of the images of a document, in
+// markdown or in HTML. A few false positives only cost a stat.
+var imagePattern = regexp.MustCompile(`!\[[^\]]*\]\(\s*(?:<([^>]+)>|([^)\s]+))|<img\s[^>]*src\s*=\s*["']?([^"'\s>]+)`)
+
+// documentImages returns the destinations of the images of a document
+func documentImages(raw string) []string {
+	var images []string
+	for _, m := range imagePattern.FindAllStringSubmatch(raw, -1) {
+		for _, dest := range m[1:] {
+			if dest != "" {
+				images = append(images, dest)
+				break
+			}
+		}
+	}
+	return images
+}
+
+// imagesKey describes the images of a document as the renderer reads them,
+// the local files by modification time and size. The remote images may change
+// anytime, a document having some is not kept on disk.
+func imagesKey(images []string) (key string, remote bool) {
+	var sb strings.Builder
+	for _, dest := range images {
+		if strings.HasPrefix(dest, "http://") || strings.HasPrefix(dest, "https://") {
+			remote = true
+			continue
+		}
+		// like the renderer, relative to the working directory
+		if info, err := os.Stat(dest); err == nil {
+			fmt.Fprintf(&sb, "%s %d %d\n", dest, info.ModTime().UnixNano(), info.Size())
+		} else {
+			fmt.Fprintf(&sb, "%s missing\n", dest)
+		}
+	}
+	return sb.String(), remote
+}
+
+// renderCache keeps the last renderings, to resize back and forth or reload
+// the same document without rendering it again
+type renderCache struct {
+	mu sync.Mutex
+	// *renderCacheEntry, the most recently used first
+	entries *list.List
+	index   map[renderKey]*list.Element
+}
+
+type renderCacheEntry struct {
+	key       renderKey
+	rendering *rendering
+}
+
+func newRenderCache() *renderCache {
+	return &renderCache{
+		entries: list.New(),
+		index:   make(map[renderKey]*list.Element),
+	}
+}
+
+func (c *renderCache) get(key renderKey) (*rendering, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.index[key]
+	if !ok {
+		return nil, false
+	}
+	c.entries.MoveToFront(elem)
+	return elem.Value.(*renderCacheEntry).rendering, true
+}
+
+func (c *renderCache) add(key renderKey, r *rendering) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.index[key]; ok {
+		elem.Value.(*renderCacheEntry).rendering = r
+		c.entries.MoveToFront(elem)
+		return
+	}
+
+	c.index[key] = c.entries.PushFront(&renderCacheEntry{key: key, rendering: r})
+	for c.entries.Len() > renderCacheSize {
+		oldest := c.entries.Back()
+		c.entries.Remove(oldest)
+		delete(c.index, oldest.Value.(*renderCacheEntry).key)
+	}
+}
+
+// diskRendering is a rendering as stored on disk
+type diskRendering struct {
+	Content  []byte
+	Headings []diskHeading
+}
+
+type diskHeading struct {
+	Level  int
+	Number string
+	Title  string
+	Line   int
+}
+
+// getRenderCacheDir returns the directory of the renderings kept on disk
+func getRenderCacheDir() (string, error) {
+	cacheHome := os.Getenv("XDG_CACHE_HOME")
+	if cacheHome == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		cacheHome = filepath.Join(homeDir, ".cache")
+	}
+	return filepath.Join(cacheHome, "mdrs", "render"), nil
+}
+
+// diskCachePath returns the file of a rendering on disk
+func diskCachePath(key renderKey) (string, error) {
+	dir, err := getRenderCacheDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%x %d %s %t %s", key.hash, key.width, key.options, key.noColor, key.images)))
+	return filepath.Join(dir, hex.EncodeToString(sum[:])), nil
+}
+
+// loadDiskRendering reads a rendering kept on disk
+func loadDiskRendering(key renderKey) (*rendering, error) {
+	p, err := diskCachePath(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+
+	var stored diskRendering
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&stored); err != nil {
+		return nil, err
+	}
+
+	// mark it as used, to keep it when pruning
+	now := time.Now()
+	_ = os.Chtimes(p, now, now)
+
+	headings := make([]heading, len(stored.Headings))
+	for i, h := range stored.Headings {
+		headings[i] = heading{level: h.Level, number: h.Number, title: h.Title, line: h.Line}
+	}
+	return newRendering(stored.Content, headings), nil
+}
+
+// saveDiskRendering keeps a rendering on disk, removing the least recently
+// used ones beyond diskCacheSize
+func saveDiskRendering(key renderKey, r *rendering) error {
+	p, err := diskCachePath(key)
+	if err != nil {
+		return err
+	}
+
+	stored := diskRendering{Content: r.content}
+	for _, h := range r.headings {
+		stored.Headings = append(stored.Headings, diskHeading{Level: h.level, Number: h.number, Title: h.title, Line: h.line})
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(stored); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+		return err
+	}
+	// write to a temp file and rename, so that a concurrent mdrs never reads
+	// a partial file
+	tmp, err := ioutil.TempFile(filepath.Dir(p), "render-*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(buf.Bytes())
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), p)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+
+	return pruneDiskCache(filepath.Dir(p))
+}
+
+// pruneDiskCache removes the least recently used renderings beyond
+// diskCacheSize
+func pruneDiskCache(dir string) error {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	if len(files) <= diskCacheSize {
+		return nil
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ModTime().After(files[j].ModTime())
+	})
+	for _, f := range files[diskCacheSize:] {
+		_ = os.Remove(filepath.Join(dir, f.Name()))
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDocumentImages(t *testing.T) {
+	tests := []struct {
+		raw      string
+		expected []string
+	}{
+		{raw: "# Title\n\ntext [link](doc.md)\n", expected: nil},
+		{raw: "![logo](logo.png)", expected: []string{"logo.png"}},
+		{raw: "![](<img/a b.png>) ![x]( b.jpg \"title\")", expected: []string{"img/a b.png", "b.jpg"}},
+		{raw: `<img alt="a" src="c.png"> <img src=d.gif>`, expected: []string{"c.png", "d.gif"}},
+		{raw: "![remote](https://example.com/e.png)", expected: []string{"https://example.com/e.png"}},
+	}
+
+	for _, test := range tests {
+		if got := documentImages(test.raw); !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%q: got %q, expected %q", test.raw, got, test.expected)
+		}
+	}
+}
+
+func TestImagesKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mdrs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	image := filepath.Join(dir, "image.png")
+	if err := ioutil.WriteFile(image, []byte("png"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	key, remote := imagesKey([]string{image})
+	if remote {
+		t.Errorf("local image seen as remote")
+	}
+
+	// a changed image gives another key
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(image, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if changed, _ := imagesKey([]string{image}); changed == key {
+		t.Errorf("same key %q after changing the image", key)
+	}
+
+	// so does a missing one
+	if missing, _ := imagesKey([]string{image + ".missing"}); missing == key {
+		t.Errorf("same key %q for a missing image", key)
+	}
+
+	if _, remote := imagesKey([]string{"http://example.com/a.png", image}); !remote {
+		t.Errorf("remote image not detected")
+	}
+}
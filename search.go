@@ -9,9 +9,15 @@ import (
 
 // SearchState manages the state of the search feature
 type SearchState struct {
-	active        bool
-	term          string
-	matches       []SearchMatch
+	active  bool
+	term    string
+	matches []SearchMatch
+	// indexes of the matches of each line
+	lineMatches map[int][]int
+	// lines of the searched content, and the ones with matches highlighted,
+	// computed when the matches or the current match change
+	lines         []string
+	highlighted   map[int][]byte
 	currentIndex  int
 	caseSensitive bool
 	config        *Config
@@ -41,6 +47,9 @@ func (s *SearchState) Clear() {
 	s.active = false
 	s.term = ""
 	s.matches = []SearchMatch{}
+	s.lineMatches = nil
+	s.lines = nil
+	s.highlighted = nil
 	s.currentIndex = -1
 }
 
@@ -51,18 +60,34 @@ func (s *SearchState) SetTerm(term string, content string) {
 	if len(s.matches) > 0 {
 		s.currentIndex = 0
 	}
+	s.highlightAll()
+}
+
+// Refresh finds the matches again in a new rendering of the content,
+// keeping the current match when it still exists
+func (s *SearchState) Refresh(content string) {
+	s.findAllMatches(content)
+	if s.currentIndex >= len(s.matches) {
+		s.currentIndex = len(s.matches) - 1
+	}
+	if s.currentIndex < 0 && len(s.matches) > 0 {
+		s.currentIndex = 0
+	}
+	s.highlightAll()
 }
 
 // findAllMatches finds all matches in the content
 func (s *SearchState) findAllMatches(content string) {
 	s.matches = []SearchMatch{}
+	s.lineMatches = make(map[int][]int)
 	if s.term == "" {
 		return
 	}
 
 	lines := strings.Split(content, "\n")
+	s.lines = lines
 	searchTerm := s.term
-	
+
 	if !s.caseSensitive {
 		searchTerm = strings.ToLower(searchTerm)
 	}
@@ -80,14 +105,15 @@ func (s *SearchState) findAllMatches(content string) {
 			if pos == -1 {
 				break
 			}
-			
+
 			actualPos := index + pos
+			s.lineMatches[lineNum] = append(s.lineMatches[lineNum], len(s.matches))
 			s.matches = append(s.matches, SearchMatch{
 				lineNumber: lineNum,
 				column:     actualPos,
 				text:       line[actualPos : actualPos+len(s.term)],
 			})
-			
+
 			index = actualPos + len(searchTerm)
 		}
 	}
@@ -99,7 +125,7 @@ func (s *SearchState) NextMatch() (SearchMatch, bool) {
 		return SearchMatch{}, false
 	}
 
-	s.currentIndex = (s.currentIndex + 1) % len(s.matches)
+	s.setCurrent((s.currentIndex + 1) % len(s.matches))
 	return s.matches[s.currentIndex], true
 }
 
@@ -109,10 +135,11 @@ func (s *SearchState) PrevMatch() (SearchMatch, bool) {
 		return SearchMatch{}, false
 	}
 
-	s.currentIndex--
-	if s.currentIndex < 0 {
-		s.currentIndex = len(s.matches) - 1
+	previous := s.currentIndex - 1
+	if previous < 0 {
+		previous = len(s.matches) - 1
 	}
+	s.setCurrent(previous)
 	return s.matches[s.currentIndex], true
 }
 
@@ -124,6 +151,16 @@ func (s *SearchState) GetCurrentMatch() (SearchMatch, bool) {
 	return s.matches[s.currentIndex], true
 }
 
+// SelectMatchFrom makes the first match at or after the given line the current one
+func (s *SearchState) SelectMatchFrom(line int) {
+	for i, match := range s.matches {
+		if match.lineNumber >= line {
+			s.setCurrent(i)
+			return
+		}
+	}
+}
+
 // GetMatchCount returns the total number of matches
 func (s *SearchState) GetMatchCount() int {
 	return len(s.matches)
@@ -134,96 +171,72 @@ func (s *SearchState) GetStatusText() string {
 	if s.term == "" {
 		return ""
 	}
-	
+
 	if len(s.matches) == 0 {
 		return fmt.Sprintf("No matches for: %s", s.term)
 	}
-	
+
 	return fmt.Sprintf("Match %d of %d: %s", s.currentIndex+1, len(s.matches), s.term)
 }
 
-// HighlightContent highlights search matches in the content
-func (s *SearchState) HighlightContent(content []byte) []byte {
-	if s.term == "" || len(s.matches) == 0 {
-		return content
+// setCurrent makes another match the current one, highlighting again only
+// the lines of the previous and of the new current match
+func (s *SearchState) setCurrent(index int) {
+	previous := s.currentIndex
+	s.currentIndex = index
+	if previous >= 0 && previous < len(s.matches) {
+		s.highlightLine(s.matches[previous].lineNumber)
 	}
+	s.highlightLine(s.matches[index].lineNumber)
+}
 
-	contentStr := string(content)
-	lines := strings.Split(contentStr, "\n")
-	
-	// Create a map of line numbers to matches for efficient lookup
-	lineMatches := make(map[int][]SearchMatch)
-	for _, match := range s.matches {
-		lineMatches[match.lineNumber] = append(lineMatches[match.lineNumber], match)
+// highlightAll highlights the matches of every line
+func (s *SearchState) highlightAll() {
+	s.highlighted = make(map[int][]byte, len(s.lineMatches))
+	for lineNumber := range s.lineMatches {
+		s.highlightLine(lineNumber)
 	}
-	
-	// Process each line that has matches
-	for lineNum, matches := range lineMatches {
-		if lineNum >= len(lines) {
-			continue
-		}
-		
-		line := lines[lineNum]
-		var newLine strings.Builder
-		lastEnd := 0
-		
-		// Sort matches by column position for this line
-		for i, match := range matches {
-			isCurrentMatch := false
-			// Check if this match is the current one
-			for j, m := range s.matches {
-				if j == s.currentIndex && m.lineNumber == match.lineNumber && m.column == match.column {
-					isCurrentMatch = true
-					break
-				}
-			}
-			
-			// Add text before the match
-			if match.column > lastEnd {
-				newLine.WriteString(line[lastEnd:match.column])
-			}
-			
-			// Add highlighted match
-			matchText := line[match.column : match.column+len(s.term)]
-			if isCurrentMatch {
-				// Current match - use config colors
-				if s.config != nil {
-					newLine.WriteString(s.config.ApplySearchHighlight(matchText, true))
-				} else {
-					// Fallback to default colors
-					newLine.WriteString("\033[43;30m")
-					newLine.WriteString(matchText)
-					newLine.WriteString("\033[0m")
-				}
-			} else {
-				// Other matches - use config colors
-				if s.config != nil {
-					newLine.WriteString(s.config.ApplySearchHighlight(matchText, false))
-				} else {
-					// Fallback to default colors
-					newLine.WriteString("\033[33m")
-					newLine.WriteString(matchText)
-					newLine.WriteString("\033[0m")
-				}
-			}
-			
-			lastEnd = match.column + len(s.term)
-			
-			// If this is the last match and there's text after it
-			if i == len(matches)-1 && lastEnd < len(line) {
-				newLine.WriteString(line[lastEnd:])
-			}
-		}
-		
-		// If there were no matches starting from position 0, add the remaining text
-		if len(matches) > 0 && lastEnd == 0 {
-			newLine.WriteString(line)
+}
+
+// highlightLine highlights the matches of a line, the current one standing out
+func (s *SearchState) highlightLine(lineNumber int) {
+	line := s.lines[lineNumber]
+	var newLine strings.Builder
+	lastEnd := 0
+	for _, i := range s.lineMatches[lineNumber] {
+		match := s.matches[i]
+		end := match.column + len(s.term)
+		if end > len(line) {
+			break
 		}
-		
-		lines[lineNum] = newLine.String()
+		newLine.WriteString(line[lastEnd:match.column])
+		newLine.WriteString(s.highlight(line[match.column:end], i == s.currentIndex))
+		lastEnd = end
 	}
-	
-	return []byte(strings.Join(lines, "\n"))
+	newLine.WriteString(line[lastEnd:])
+
+	s.highlighted[lineNumber] = []byte(newLine.String())
+}
+
+// HighlightLine returns a line of the rendered content with its matches
+// highlighted, or the line itself without match
+func (s *SearchState) HighlightLine(lineNumber int, line []byte) []byte {
+	if highlighted, ok := s.highlighted[lineNumber]; ok {
+		return highlighted
+	}
+	return line
+}
+
+// highlight colors the text of a match
+func (s *SearchState) highlight(text string, current bool) string {
+	if s.config != nil {
+		return s.config.ApplySearchHighlight(text, current)
+	}
+	// Fallback to default colors
+	if current {
+		return "\033[43;30m" + text + "\033[0m"
+	}
+	return "\033[33m" + text + "\033[0m"
 }
 
 // HandleSearchInput handles input in the search view
@@ -235,4 +248,4 @@ func (s *SearchState) HandleSearchInput(g *gocui.Gui, v *gocui.View) error {
 // ToggleCaseSensitive toggles case-sensitive search
 func (s *SearchState) ToggleCaseSensitive() {
 	s.caseSensitive = !s.caseSensitive
-}
\ No newline at end of file
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// searchDocument generates a rendered document with 3 matches of "find" on
+// each of its lines
+func searchDocument(lines int) string {
+	var sb strings.Builder
+	for i := 0; i < lines; i++ {
+		fmt.Fprintf(&sb, "line %d: find this, find that and find more\n", i)
+	}
+	return sb.String()
+}
+
+// changedLines counts the highlighted lines that were computed again
+func changedLines(before, after map[int][]byte) int {
+	changed := 0
+	for line, h := range after {
+		if &before[line][0] != &h[0] {
+			changed++
+		}
+	}
+	return changed
+}
+
+func TestNextMatchHighlightsTwoLines(t *testing.T) {
+	s := NewSearchState(DefaultConfig())
+	s.SetTerm("find", searchDocument(100))
+
+	tests := []struct {
+		name    string
+		move    func()
+		changed int
+	}{
+		// the next match is on the same line
+		{"next on the same line", func() { s.NextMatch() }, 1},
+		{"next on the next line", func() { s.NextMatch(); s.NextMatch() }, 2},
+		{"previous on the previous line", func() { s.PrevMatch() }, 2},
+		{"previous wrapping to the end", func() { s.SelectMatchFrom(0); s.PrevMatch() }, 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			before := make(map[int][]byte, len(s.highlighted))
+			for line, h := range s.highlighted {
+				before[line] = h
+			}
+			test.move()
+			if changed := changedLines(before, s.highlighted); changed > test.changed {
+				t.Errorf("%d lines highlighted again, expected at most %d", changed, test.changed)
+			}
+		})
+	}
+}
+
+func TestHighlightLine(t *testing.T) {
+	config := DefaultConfig()
+	s := NewSearchState(config)
+	s.SetTerm("find", "a find b find\nnothing\n")
+
+	current := config.ApplySearchHighlight("find", true)
+	other := config.ApplySearchHighlight("find", false)
+
+	tests := []struct {
+		line     int
+		text     string
+		expected string
+	}{
+		{0, "a find b find", "a " + current + " b " + other},
+		{1, "nothing", "nothing"},
+	}
+
+	for _, test := range tests {
+		if got := string(s.HighlightLine(test.line, []byte(test.text))); got != test.expected {
+			t.Errorf("line %d: got %q, expected %q", test.line, got, test.expected)
+		}
+	}
+
+	s.NextMatch()
+	expected := "a " + other + " b " + current
+	if got := string(s.HighlightLine(0, []byte("a find b find"))); got != expected {
+		t.Errorf("after next: got %q, expected %q", got, expected)
+	}
+}
+
+// benchmarkLines gives about 30k matches
+const benchmarkLines = 10000
+
+func BenchmarkSetTerm(b *testing.B) {
+	content := searchDocument(benchmarkLines)
+	s := NewSearchState(DefaultConfig())
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.SetTerm("find", content)
+	}
+}
+
+func BenchmarkNextMatch(b *testing.B) {
+	s := NewSearchState(DefaultConfig())
+	s.SetTerm("find", searchDocument(benchmarkLines))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.NextMatch()
+	}
+}
+
+func BenchmarkPrevMatch(b *testing.B) {
+	s := NewSearchState(DefaultConfig())
+	s.SetTerm("find", searchDocument(benchmarkLines))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.PrevMatch()
+	}
+}
+
+// BenchmarkHighlightLine writes a screen of lines, as done on each layout
+func BenchmarkHighlightLine(b *testing.B) {
+	content := searchDocument(benchmarkLines)
+	lines := strings.Split(content, "\n")
+	s := NewSearchState(DefaultConfig())
+	s.SetTerm("find", content)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		first := i % (benchmarkLines - 30)
+		for line := first; line < first+30; line++ {
+			_ = s.HighlightLine(line, []byte(lines[line]))
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/awesome-gocui/gocui"
+)
+
+// maxStateFiles is the number of files remembered in the state file. The
+// least recently opened ones are forgotten first.
+const maxStateFiles = 500
+
+// position is a location in the document, anchored to the closest heading
+// above it so that it survives a change of rendering width.
+type position struct {
+	Section string `json:"section,omitempty"` // heading numbering, like "1.2"
+	Title   string `json:"title,omitempty"`
+	Offset  int    `json:"offset"` // lines after the heading
+	Line    int    `json:"line"`   // absolute line, used when the heading is gone
+}
+
+// fileState is what is remembered about a file between sessions
+type fileState struct {
+	Position   position            `json:"position"`
+	Search     string              `json:"search,omitempty"`
+	Marks      map[string]position `json:"marks,omitempty"`
+	LastOpened time.Time           `json:"last_opened"`
+}
+
+// State holds the per-file state of all the files opened with mdrs
+type State struct {
+	Files map[string]*fileState `json:"files"`
+}
+
+// errStateParse is the error of a corrupted state file, which gets replaced
+var errStateParse = errors.New("failed to parse state file")
+
+// getStatePath returns the path to the state file
+func getStatePath() string {
+	stateHome := os.Getenv("XDG_STATE_HOME")
+	if stateHome == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			// Fallback to current directory
+			return "mdrs-state.json"
+		}
+		stateHome = filepath.Join(homeDir, ".local", "state")
+	}
+	return filepath.Join(stateHome, "mdrs", "state.json")
+}
+
+// LoadState loads the state file, returning an empty state if it doesn't exist
+func LoadState() (*State, error) {
+	state := &State{Files: make(map[string]*fileState)}
+
+	data, err := ioutil.ReadFile(getStatePath())
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return state, fmt.Errorf("failed to read state file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, state); err != nil {
+		return &State{Files: make(map[string]*fileState)}, fmt.Errorf("%w: %v", errStateParse, err)
+	}
+	if state.Files == nil {
+		state.Files = make(map[string]*fileState)
+	}
+
+	return state, nil
+}
+
+// Save writes the state file, forgetting the oldest files if needed
+func (s *State) Save() error {
+	s.prune()
+
+	statePath := getStatePath()
+	if err := os.MkdirAll(filepath.Dir(statePath), 0700); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal state: %w", err)
+	}
+
+	// Write to a temporary file first so that a crash never leaves a
+	// truncated state file behind. Each instance has its own, for two of them
+	// closing at the same time.
+	tmp, err := ioutil.TempFile(filepath.Dir(statePath), "state-*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), statePath)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+
+	return nil
+}
+
+func (s *State) prune() {
+	if len(s.Files) <= maxStateFiles {
+		return
+	}
+
+	paths := make([]string, 0, len(s.Files))
+	for path := range s.Files {
+		paths = append(paths, path)
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		return s.Files[paths[i]].LastOpened.After(s.Files[paths[j]].LastOpened)
+	})
+	for _, path := range paths[maxStateFiles:] {
+		delete(s.Files, path)
+	}
+}
+
+// positionAt returns the anchored position of a line of the rendered document
+func (ui *ui) positionAt(line int) position {
+	pos := position{Line: line}
+	if h, ok := headingAt(ui.headings, line); ok {
+		pos.Section = h.number
+		pos.Title = h.title
+		pos.Offset = line - h.line
+	}
+	return pos
+}
+
+// lineOf returns the line of the rendered document at the given position
+func (ui *ui) lineOf(pos position) int {
+	if pos.Section != "" || pos.Title != "" {
+		// prefer an exact match, then a heading with the same title
+		for _, h := range ui.headings {
+			if h.line >= 0 && h.number == pos.Section && h.title == pos.Title {
+				return h.line + pos.Offset
+			}
+		}
+		for _, h := range ui.headings {
+			if h.line >= 0 && h.title == pos.Title {
+				return h.line + pos.Offset
+			}
+		}
+	}
+	return pos.Line
+}
+
+// loadState loads the marks of the file and, if restorePosition is set,
+// prepares the remembered position to be restored.
+func (ui *ui) loadState(restorePosition bool) error {
+	if ui.filePath == "" {
+		return nil
+	}
+
+	state, err := LoadState()
+	if err != nil {
+		return err
+	}
+
+	fs, ok := state.Files[ui.filePath]
+	if !ok {
+		return nil
+	}
+
+	for name, pos := range fs.Marks {
+		if len(name) == 1 {
+			ui.marks[rune(name[0])] = pos
+		}
+	}
+
+	if restorePosition {
+		ui.restore = fs
+	}
+
+	return nil
+}
+
+// restoreState applies the remembered state of the file, once rendered
+func (ui *ui) restoreState(g *gocui.Gui) {
+	fs := ui.restore
+	ui.restore = nil
+
+	_, maxY := g.Size()
+	ui.YOffset = max(min(ui.lineOf(fs.Position), ui.lines-maxY+1), 0)
+
+	if fs.Search != "" {
+		ui.search.SetTerm(fs.Search, string(ui.renderedContent))
+		ui.search.SelectMatchFrom(ui.YOffset)
+	}
+}
+
+// saveState records the current state of the file in the state file
+func (ui *ui) saveState() error {
+	// Nothing to record for stdin, or if the file was never displayed
+	if ui.filePath == "" || ui.restore != nil {
+		return nil
+	}
+
+	// Reload the state in case another instance changed it meanwhile. A
+	// corrupted state file gets replaced, but one that can't be read is kept
+	// rather than losing the state of the other files.
+	state, err := LoadState()
+	if err != nil && !errors.Is(err, errStateParse) {
+		return err
+	}
+
+	marks := make(map[string]position, len(ui.marks))
+	for name, pos := range ui.marks {
+		marks[string(name)] = pos
+	}
+
+	state.Files[ui.filePath] = &fileState{
+		Position:   ui.positionAt(ui.YOffset),
+		Search:     ui.search.term,
+		Marks:      marks,
+		LastOpened: time.Now(),
+	}
+
+	return state.Save()
+}
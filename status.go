@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/awesome-gocui/gocui"
+	"github.com/mattn/go-runewidth"
+)
+
+// DefaultStatusFormat is the status line format used when none is configured.
+//
+// Available placeholders:
+//
+//	{file}    name of the displayed file
+//	{line}    line at the top of the screen
+//	{lines}   total number of lines
+//	{percent} position in the document, in percent
+//	{section} title of the current section
+//	{search}  search status
+const DefaultStatusFormat = "{file}  {line}/{lines} {percent}%  {section}  {search}"
+
+// messageDuration is how long a message stays in the status line
+const messageDuration = 3 * time.Second
+
+// warningDuration is how long a warning stays in the status line
+const warningDuration = 10 * time.Second
+
+// statusText expands the status line format with the current state of the ui
+func (ui *ui) statusText(height int) string {
+	if ui.message != "" {
+		return ui.message
+	}
+
+	file := ui.fileName
+	if file == "" {
+		file = "[stdin]"
+	}
+
+	// the section of the first row on screen
+	section := ""
+	if h, ok := headingAt(ui.headings, ui.topLine()); ok {
+		section = fmt.Sprintf("%s %s", h.number, h.title)
+	}
+
+	search := ui.search.GetStatusText()
+	if ui.searchActive {
+		search = "Searching..."
+	}
+
+	replacer := strings.NewReplacer(
+		"{file}", file,
+		"{line}", strconv.Itoa(min(ui.YOffset+1, max(ui.lines, 1))),
+		"{lines}", strconv.Itoa(ui.lines),
+		"{percent}", strconv.Itoa(ui.percent(height)),
+		"{section}", section,
+		"{search}", search,
+	)
+
+	status := replacer.Replace(ui.config.StatusFormat)
+	if ui.loading {
+		status += "  loading…"
+	}
+	return status
+}
+
+// percent returns how far the view is scrolled in the document
+func (ui *ui) percent(height int) int {
+	maxOffset := ui.lines - height + 1
+	if maxOffset <= 0 {
+		return 100
+	}
+	return min(ui.YOffset*100/maxOffset, 100)
+}
+
+// layoutStatus draws the status line on the given row
+func (ui *ui) layoutStatus(g *gocui.Gui, y int) error {
+	maxX, maxY := g.Size()
+
+	sv, err := g.SetView(statusView, -1, y-1, maxX, y+1, 0)
+	if err != nil {
+		if !gocui.IsUnknownView(err) {
+			return err
+		}
+		sv.Frame = false
+		sv.Wrap = false
+		sv.FgColor = gocui.ColorDefault | gocui.AttrReverse
+	}
+	sv.Clear()
+
+	status := " " + ui.statusText(maxY)
+	_, _ = fmt.Fprint(sv, runewidth.Truncate(status, maxX, "…"))
+
+	return nil
+}
+
+// setMessage displays a message in the status line for a short time
+func (ui *ui) setMessage(g *gocui.Gui, message string) {
+	ui.showMessage(g, message, messageDuration)
+}
+
+// showMessage displays a message in the status line for the given duration
+func (ui *ui) showMessage(g *gocui.Gui, message string, duration time.Duration) {
+	ui.message = message
+	ui.messageID++
+
+	id := ui.messageID
+	time.AfterFunc(duration, func() {
+		g.Update(func(g *gocui.Gui) error {
+			// a newer message may have replaced this one
+			if ui.messageID == id {
+				ui.message = ""
+			}
+			return nil
+		})
+	})
+}
+
+func (ui *ui) toggleStatus(g *gocui.Gui, v *gocui.View) error {
+	ui.showStatus = !ui.showStatus
+	return nil
+}
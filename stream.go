@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/awesome-gocui/gocui"
+	"github.com/pkg/errors"
+)
+
+// streamInterval is how often the part of a document read so far is rendered
+const streamInterval = 100 * time.Millisecond
+
+// documentStream is a document read in the background, rendered block by
+// block as it arrives
+type documentStream struct {
+	mu   sync.Mutex
+	data []byte
+	done bool
+	err  error
+
+	// offset up to which the data has been scanned for blocks
+	scanned int
+	// whether the scanned data ends inside a fenced code block
+	inFence bool
+	// end of the last complete block
+	boundary int
+
+	// number of the latest update of the ui
+	latest int64
+}
+
+// streamDocument reads the document from r in the background, and updates
+// the ui with its complete blocks as they arrive
+func (ui *ui) streamDocument(g *gocui.Gui, r io.Reader, encoding string) {
+	ui.loading = true
+	s := &documentStream{}
+
+	go s.read(g, ui, r)
+
+	go func() {
+		ticker := time.NewTicker(streamInterval)
+		defer ticker.Stop()
+
+		shown := 0
+		// the encoding of the partial documents, detected once from the
+		// first block
+		var partial *textEncoding
+		for range ticker.C {
+			s.mu.Lock()
+			done, err := s.done, s.err
+			boundary := s.scanBlocks()
+			var data []byte
+			if done {
+				data = s.data
+			} else if boundary > shown {
+				data = s.data[:boundary]
+			}
+			s.mu.Unlock()
+
+			if done {
+				ui.finishStream(g, s, data, encoding, err)
+				return
+			}
+			if data == nil {
+				continue
+			}
+			if partial == nil {
+				partial = streamEncoding(data, encoding)
+			}
+			if !streamable(partial) {
+				continue
+			}
+
+			shown = boundary
+			text := partial.decodeText(data)
+			id := s.nextUpdate()
+			g.Update(func(g *gocui.Gui) error {
+				if s.isLatest(id) {
+					ui.setContent([]byte(text))
+				}
+				return nil
+			})
+		}
+	}()
+}
+
+// nextUpdate numbers an update of the ui. gocui runs the updates in no
+// particular order, so the ones overtaken by a newer one are dropped, for a
+// partial document to never replace a more complete one.
+func (s *documentStream) nextUpdate() int64 {
+	return atomic.AddInt64(&s.latest, 1)
+}
+
+func (s *documentStream) isLatest(id int64) bool {
+	return atomic.LoadInt64(&s.latest) == id
+}
+
+// read accumulates the document until EOF or an error
+func (s *documentStream) read(g *gocui.Gui, ui *ui, in io.Reader) {
+	r, name, err := decompressReader(in)
+	if err != nil {
+		s.finish(errors.Wrap(err, "error while decompressing"))
+		return
+	}
+	if name != "" {
+		g.Update(func(g *gocui.Gui) error {
+			ui.fileName = name
+			return nil
+		})
+	}
+
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			s.mu.Lock()
+			s.data = append(s.data, buf[:n]...)
+			s.mu.Unlock()
+		}
+		if err == io.EOF {
+			s.finish(nil)
+			return
+		}
+		if err != nil {
+			s.finish(errors.Wrap(err, "error while reading STDIN"))
+			return
+		}
+	}
+}
+
+func (s *documentStream) finish(err error) {
+	s.mu.Lock()
+	s.done = true
+	s.err = err
+	s.mu.Unlock()
+}
+
+// scanBlocks scans the data received since the last call, and returns the end
+// of the last complete block: after a blank line outside of a fenced code
+// block. Only complete lines are scanned.
+func (s *documentStream) scanBlocks() int {
+	for {
+		end := bytes.IndexByte(s.data[s.scanned:], '\n')
+		if end < 0 {
+			return s.boundary
+		}
+		line := strings.TrimSpace(string(s.data[s.scanned : s.scanned+end]))
+		s.scanned += end + 1
+
+		switch {
+		case strings.HasPrefix(line, "```") || strings.HasPrefix(line, "~~~"):
+			s.inFence = !s.inFence
+		case line == "" && !s.inFence:
+			s.boundary = s.scanned
+		}
+	}
+}
+
+// streamEncoding returns the encoding of a document from its first block,
+// either the one given or the detected one
+func streamEncoding(data []byte, encoding string) *textEncoding {
+	if encoding != "" && encoding != autoEncoding {
+		e, _ := encodingByName(encoding)
+		return e
+	}
+	return detectEncoding(data)
+}
+
+// streamable tells if the beginning of a document can be decoded on its own.
+// The blocks are cut on bytes, which splits the characters of UTF-16, so
+// UTF-16 documents are only shown once complete.
+func streamable(e *textEncoding) bool {
+	return e != nil && !strings.HasPrefix(e.name, "utf-16")
+}
+
+// finishStream shows the whole document once read, or the error that
+// stopped the reading with what was read until then. The encoding is detected
+// again on the whole document, the first block alone may be misleading.
+func (ui *ui) finishStream(g *gocui.Gui, s *documentStream, data []byte, encoding string, err error) {
+	text, decodeErr := decodeText(data, encoding)
+	id := s.nextUpdate()
+	g.Update(func(g *gocui.Gui) error {
+		ui.loading = false
+		if decodeErr == nil && s.isLatest(id) {
+			ui.setContent([]byte(text))
+		}
+		switch {
+		case err != nil:
+			ui.showMessage(g, err.Error(), warningDuration)
+		case decodeErr != nil:
+			ui.showMessage(g, decodeErr.Error(), warningDuration)
+		}
+		return nil
+	})
+}
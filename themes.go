@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+//go:generate go run themes_generate.go
+
+// theme is a built-in set of colors, selected with the theme setting. The
+// themes other than the default come from the config files of the themes
+// directory, built in by go generate.
+type theme struct {
+	name   string
+	colors ColorConfig
+}
+
+// themeFile is a config file of the themes directory
+type themeFile struct {
+	name string
+	data string
+}
+
+var themes = loadThemes()
+
+// loadThemes reads the colors of the built-in theme files
+func loadThemes() []theme {
+	result := []theme{{name: "default", colors: DefaultConfig().Colors}}
+	for _, f := range themeFiles {
+		var config struct {
+			Colors ColorConfig `json:"colors"`
+		}
+		if err := json.Unmarshal([]byte(f.data), &config); err != nil {
+			panic(fmt.Sprintf("invalid built-in theme %s: %v", f.name, err))
+		}
+		result = append(result, theme{name: f.name, colors: config.Colors})
+	}
+	return result
+}
+
+// themeByName returns the built-in theme with the given name
+func themeByName(name string) (*theme, error) {
+	for i := range themes {
+		if themes[i].name == strings.ToLower(name) {
+			return &themes[i], nil
+		}
+	}
+	return nil, fmt.Errorf("unknown theme %q, expected %s", name, themeNames())
+}
+
+// themeNames lists the names of the built-in themes
+func themeNames() string {
+	names := make([]string, len(themes))
+	for i, t := range themes {
+		names[i] = t.name
+	}
+	return strings.Join(names[:len(names)-1], ", ") + " or " + names[len(names)-1]
+}
@@ -0,0 +1,61 @@
+// Code generated by go run themes_generate.go; DO NOT EDIT.
+
+package main
+
+// themeFiles are the files of the themes directory
+var themeFiles = []themeFile{
+	{name: "dracula", data: `{
+  "colors": {
+    "heading1": "#bd93f9",
+    "heading2": "#ff79c6",
+    "heading3": "#8be9fd",
+    "heading4": "#50fa7b",
+    "heading5": "#ffb86c",
+    "heading6": "#f1fa8c",
+    "bold": "#f8f8f2",
+    "italic": "#f1fa8c",
+    "strikethrough": "#6272a4",
+    "link": "#8be9fd",
+    "link_url": "#6272a4",
+    "code": "#50fa7b",
+    "code_block": "#f8f8f2",
+    "code_block_bg": "#282a36",
+    "list_marker": "#ff79c6",
+    "task_checked": "#50fa7b",
+    "task_unchecked": "#ff5555",
+    "blockquote": "#6272a4",
+    "table_header": "#bd93f9",
+    "table_row": "#f8f8f2",
+    "table_border": "#44475a",
+    "search_current": "#f1fa8c",
+    "search_match": "#ffb86c"
+  }
+}`},
+	{name: "solarized-dark", data: `{
+  "colors": {
+    "heading1": "#268bd2",
+    "heading2": "#2aa198",
+    "heading3": "#859900",
+    "heading4": "#b58900",
+    "heading5": "#cb4b16",
+    "heading6": "#d33682",
+    "bold": "#93a1a1",
+    "italic": "#b58900",
+    "strikethrough": "#586e75",
+    "link": "#268bd2",
+    "link_url": "#657b83",
+    "code": "#2aa198",
+    "code_block": "#839496",
+    "code_block_bg": "#073642",
+    "list_marker": "#cb4b16",
+    "task_checked": "#859900",
+    "task_unchecked": "#dc322f",
+    "blockquote": "#586e75",
+    "table_header": "#268bd2",
+    "table_row": "#839496",
+    "table_border": "#586e75",
+    "search_current": "#b58900",
+    "search_match": "#cb4b16"
+  }
+}`},
+}
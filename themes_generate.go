@@ -0,0 +1,49 @@
+//go:build ignore
+// +build ignore
+
+// themes_generate writes themes_data.go from the themes directory, so that
+// the built-in themes and the theme files are the same
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	paths, err := filepath.Glob(filepath.Join("themes", "*.json"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("// Code generated by go run themes_generate.go; DO NOT EDIT.\n\n")
+	buf.WriteString("package main\n\n")
+	buf.WriteString("// themeFiles are the files of the themes directory\n")
+	buf.WriteString("var themeFiles = []themeFile{\n")
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if bytes.IndexByte(data, '`') >= 0 {
+			log.Fatalf("%s: backquotes are not supported", path)
+		}
+		name := strings.TrimSuffix(filepath.Base(path), ".json")
+		fmt.Fprintf(&buf, "\t{name: %q, data: `%s`},\n", name, data)
+	}
+	buf.WriteString("}\n")
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := ioutil.WriteFile("themes_data.go", src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}